@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -10,6 +11,75 @@ import (
 // Config holds all user configuration
 type Config struct {
 	Display DisplayConfig `toml:"display"`
+
+	// Proxies maps a URL scheme (e.g. "https", "gopher") to the host[:port]
+	// of a Gemini proxy that fetches URLs of that scheme
+	Proxies map[string]string `toml:"proxies"`
+
+	Titan TitanConfig `toml:"titan"`
+
+	Cache CacheConfig `toml:"cache"`
+
+	Network NetworkConfig `toml:"network"`
+
+	// Handlers maps a MIME type or pattern (e.g. "image/*") to a program that
+	// opens responses of that type, which are not shown as pages. The file is
+	// passed as the program's last argument. Other such responses are saved
+	// to the downloads directory
+	Handlers map[string]string `toml:"handlers"`
+
+	Downloads DownloadsConfig `toml:"downloads"`
+}
+
+// DownloadsConfig holds settings for saving files
+type DownloadsConfig struct {
+	// Dir is where downloads are saved ("" = DownloadsDir())
+	Dir string `toml:"dir"`
+}
+
+// NetworkConfig holds settings for how requests are made
+type NetworkConfig struct {
+	// RetrySlowDown is the longest wait asked for by a 44 SLOW DOWN response
+	// after which the page is reloaded automatically ("0s" = never)
+	RetrySlowDown Duration `toml:"retry_slow_down"`
+}
+
+// CacheConfig holds settings for the page cache used by back/forward
+type CacheConfig struct {
+	// MemoryEntries is the number of pages kept in memory
+	MemoryEntries int `toml:"memory_entries"`
+
+	// Disk also stores pages on disk, so they survive restarts
+	Disk bool `toml:"disk"`
+
+	// DiskSizeMB limits the size of the disk cache (0 = no limit)
+	DiskSizeMB int `toml:"disk_size_mb"`
+
+	// MaxAge is how long a cached page is used before it is fetched again
+	MaxAge Duration `toml:"max_age"`
+}
+
+// Duration is a time.Duration written as a string such as "30m" or "24h"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+// MarshalText formats the duration as a string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// TitanConfig holds settings for uploading pages with the Titan protocol
+type TitanConfig struct {
+	// Tokens maps a host to the authentication token sent with uploads to it
+	Tokens map[string]string `toml:"tokens"`
 }
 
 // DisplayConfig holds display-related settings
@@ -28,17 +98,88 @@ func DefaultConfig() *Config {
 			WrapWidth:       100, // Default to 100 characters
 			ShowLineNumbers: false,
 		},
+		Cache: CacheConfig{
+			MemoryEntries: 100,
+			Disk:          false,
+			DiskSizeMB:    50,
+			MaxAge:        Duration{24 * time.Hour},
+		},
+		Network: NetworkConfig{
+			RetrySlowDown: Duration{30 * time.Second},
+		},
+	}
+}
+
+// ConfigDir returns the directory holding the configuration file and
+// other persistent client data
+func ConfigDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
 	}
+
+	return filepath.Join(configDir, "gemini-client"), nil
 }
 
 // ConfigPath returns the path to the configuration file
 func ConfigPath() (string, error) {
-	configDir, err := os.UserConfigDir()
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "config.toml"), nil
+}
+
+// KnownHostsPath returns the path to the TOFU known hosts file
+func KnownHostsPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "certificates", "known_hosts.json"), nil
+}
+
+// IdentitiesDir returns the directory where client identities are stored,
+// next to the known hosts file
+func IdentitiesDir() (string, error) {
+	dir, err := ConfigDir()
 	if err != nil {
 		return "", err
 	}
 
-	return filepath.Join(configDir, "gemini-client", "config.toml"), nil
+	return filepath.Join(dir, "certificates", "client"), nil
+}
+
+// BookmarksPath returns the path to the bookmarks file
+func BookmarksPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "bookmarks.json"), nil
+}
+
+// CacheDir returns the directory of the on-disk page cache
+func CacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "gemini-client", "pages"), nil
+}
+
+// DownloadsDir returns the default directory files are saved to,
+// ~/Downloads. It is created by the first download if it doesn't exist
+func DownloadsDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Downloads"), nil
 }
 
 // Load loads the configuration from the default location
@@ -55,13 +196,13 @@ func Load() (*Config, error) {
 		return DefaultConfig(), nil
 	}
 
-	// Load the config file
-	var cfg Config
-	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+	// Load the config file over the defaults, so omitted settings keep them
+	cfg := DefaultConfig()
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
 		return nil, err
 	}
 
-	return &cfg, nil
+	return cfg, nil
 }
 
 // Save saves the configuration to the default location
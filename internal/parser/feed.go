@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// atomFeed is the part of an Atom feed shown as a document
+type atomFeed struct {
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+// atomEntry is an entry of an Atom feed
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Summary   string     `xml:"summary"`
+}
+
+// atomLink is an Atom link element
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// FeedParser converts an Atom feed into a gemlog-style document: the feed
+// title as a heading and a dated link line for each entry. A feed can only
+// be parsed once all of it has arrived, so its lines are returned by Flush
+type FeedParser struct {
+	raw strings.Builder
+}
+
+// NewFeedParser creates a parser for Atom feeds
+func NewFeedParser() *FeedParser {
+	return &FeedParser{}
+}
+
+// ParseLine collects a line of the feed; it never returns a line
+func (p *FeedParser) ParseLine(raw string) *Line {
+	p.raw.WriteString(raw)
+	p.raw.WriteString("\n")
+	return nil
+}
+
+// Flush parses the collected feed into document lines
+func (p *FeedParser) Flush() []*Line {
+	var feed atomFeed
+	if err := xml.Unmarshal([]byte(p.raw.String()), &feed); err != nil {
+		return []*Line{
+			{Type: LineTypeHeading1, Text: "Unreadable feed"},
+			{Type: LineTypeText, Text: fmt.Sprintf("The feed could not be parsed: %v", err)},
+		}
+	}
+
+	title := strings.TrimSpace(feed.Title)
+	if title == "" {
+		title = "Feed"
+	}
+	lines := []*Line{{Type: LineTypeHeading1, Raw: "# " + title, Text: title}}
+	if subtitle := strings.TrimSpace(feed.Subtitle); subtitle != "" {
+		lines = append(lines, &Line{Type: LineTypeText, Raw: subtitle, Text: subtitle})
+	}
+	if href := atomHref(feed.Links); href != "" {
+		lines = append(lines, linkLine("", href, "Home page"))
+	}
+	lines = append(lines, &Line{Type: LineTypeText})
+
+	if len(feed.Entries) == 0 {
+		lines = append(lines, &Line{Type: LineTypeText, Text: "This feed has no entries."})
+	}
+	for _, entry := range feed.Entries {
+		href := atomHref(entry.Links)
+		if href == "" {
+			continue
+		}
+
+		label := strings.TrimSpace(entry.Title)
+		if label == "" {
+			label = href
+		}
+		if date := atomDate(entry); date != "" {
+			label = date + " - " + label
+		}
+		lines = append(lines, linkLine("", href, label))
+	}
+
+	return lines
+}
+
+// atomHref returns the alternate (page) link among an element's links
+func atomHref(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
+
+// atomDate returns the date an entry was published or last updated
+func atomDate(entry atomEntry) string {
+	stamp := entry.Published
+	if stamp == "" {
+		stamp = entry.Updated
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(stamp))
+	if err != nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
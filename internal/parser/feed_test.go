@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAtomFeed(t *testing.T) {
+	input := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example Gemlog</title>
+  <subtitle>Notes from a capsule</subtitle>
+  <link href="gemini://example.org/gemlog/" rel="alternate"/>
+  <link href="gemini://example.org/gemlog/atom.xml" rel="self"/>
+  <entry>
+    <title>Second post</title>
+    <link href="gemini://example.org/gemlog/2.gmi"/>
+    <updated>2024-03-02T10:00:00Z</updated>
+  </entry>
+  <entry>
+    <title>First post</title>
+    <link href="gemini://example.org/gemlog/1.gmi" rel="alternate"/>
+    <published>2024-01-15T08:30:00+01:00</published>
+    <updated>2024-02-01T00:00:00Z</updated>
+  </entry>
+</feed>
+`
+
+	doc, err := ParseLines(strings.NewReader(input), NewFeedParser())
+	if err != nil {
+		t.Fatalf("ParseLines failed: %v", err)
+	}
+
+	if len(doc.Headings) != 1 || doc.Headings[0].Text != "Example Gemlog" {
+		t.Errorf("Expected the feed title as heading, got %+v", doc.Headings)
+	}
+
+	expected := []struct{ url, text string }{
+		{"gemini://example.org/gemlog/", "Home page"},
+		{"gemini://example.org/gemlog/2.gmi", "2024-03-02 - Second post"},
+		{"gemini://example.org/gemlog/1.gmi", "2024-01-15 - First post"},
+	}
+	if len(doc.Links) != len(expected) {
+		t.Fatalf("Expected %d links, got %d", len(expected), len(doc.Links))
+	}
+	for i, e := range expected {
+		if doc.Links[i].Link.URL != e.url || doc.Links[i].Text != e.text {
+			t.Errorf("Link %d: expected %q %q, got %q %q", i, e.url, e.text, doc.Links[i].Link.URL, doc.Links[i].Text)
+		}
+	}
+}
+
+func TestParseInvalidFeed(t *testing.T) {
+	doc, err := ParseLines(strings.NewReader("<feed><entry>"), NewFeedParser())
+	if err != nil {
+		t.Fatalf("ParseLines failed: %v", err)
+	}
+	if doc.LineCount() == 0 || doc.Lines[0].Text != "Unreadable feed" {
+		t.Error("Expected an unreadable feed message")
+	}
+}
@@ -20,14 +20,10 @@ func NewParser() *Parser {
 func (p *Parser) Parse(r io.Reader) (*Document, error) {
 	doc := NewDocument()
 	scanner := bufio.NewScanner(r)
-
-	inPreformat := false
-	var preformatAltText string
+	sp := &StreamParser{p: p}
 
 	for scanner.Scan() {
-		rawLine := scanner.Text()
-		line := p.parseLine(rawLine, &inPreformat, &preformatAltText)
-		doc.AddLine(line)
+		doc.AddLine(sp.ParseLine(scanner.Text()))
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -42,6 +38,26 @@ func (p *Parser) ParseString(s string) (*Document, error) {
 	return p.Parse(strings.NewReader(s))
 }
 
+// StreamParser parses a gemtext document one line at a time, carrying the
+// preformat state from line to line. It allows documents to be rendered
+// while they are still being received
+type StreamParser struct {
+	p                *Parser
+	inPreformat      bool
+	preformatAltText string
+}
+
+// NewStreamParser creates a parser for incrementally received gemtext
+func NewStreamParser() *StreamParser {
+	return &StreamParser{p: NewParser()}
+}
+
+// ParseLine parses the next line of the document
+// The line should not include its CR/LF terminator
+func (s *StreamParser) ParseLine(raw string) *Line {
+	return s.p.parseLine(raw, &s.inPreformat, &s.preformatAltText)
+}
+
 // parseLine parses a single line of gemtext
 func (p *Parser) parseLine(raw string, inPreformat *bool, preformatAltText *string) *Line {
 	line := &Line{
@@ -82,6 +98,14 @@ func (p *Parser) parseLine(raw string, inPreformat *bool, preformatAltText *stri
 		return line
 	}
 
+	// Check for prompt link line (Spartan)
+	if strings.HasPrefix(raw, "=:") {
+		line.Type = LineTypePromptLink
+		line.Link = parseLink(raw)
+		line.Text = line.Link.Display
+		return line
+	}
+
 	// Check for heading lines
 	if strings.HasPrefix(raw, "#") {
 		if strings.HasPrefix(raw, "###") {
@@ -126,8 +150,8 @@ func (p *Parser) parseLine(raw string, inPreformat *bool, preformatAltText *stri
 // parseLink parses a link line and extracts URL and label
 // Format: => <URL> [<LABEL>]
 func parseLink(raw string) *LinkInfo {
-	// Remove the => prefix
-	content := strings.TrimPrefix(raw, "=>")
+	// Remove the => or =: prefix
+	content := raw[2:]
 	content = strings.TrimSpace(content)
 
 	if content == "" {
@@ -111,6 +111,36 @@ func TestParseLink(t *testing.T) {
 	}
 }
 
+func TestParsePromptLink(t *testing.T) {
+	doc, err := ParseString("=: /search Search the capsule\n=> /about About")
+	if err != nil {
+		t.Fatalf("ParseString failed: %v", err)
+	}
+
+	if len(doc.Lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(doc.Lines))
+	}
+
+	line := doc.Lines[0]
+	if line.Type != LineTypePromptLink {
+		t.Errorf("Expected LineTypePromptLink, got %v", line.Type)
+	}
+	if line.Link == nil {
+		t.Fatal("Link info is nil")
+	}
+	if line.Link.URL != "/search" {
+		t.Errorf("Expected URL %q, got %q", "/search", line.Link.URL)
+	}
+	if line.Link.Display != "Search the capsule" {
+		t.Errorf("Expected display %q, got %q", "Search the capsule", line.Link.Display)
+	}
+
+	// Prompt links are navigable like ordinary links
+	if doc.LinkCount() != 2 {
+		t.Errorf("Expected 2 links, got %d", doc.LinkCount())
+	}
+}
+
 func TestParseListItem(t *testing.T) {
 	input := "* List item"
 	doc, err := ParseString(input)
@@ -309,3 +339,31 @@ func TestMultilineDocument(t *testing.T) {
 		t.Errorf("Expected 1 heading, got %d", doc.HeadingCount())
 	}
 }
+
+func TestStreamParserMatchesParse(t *testing.T) {
+	input := []string{
+		"# Log",
+		"```raw",
+		"=> not a link",
+		"```",
+		"=> gemini://example.com Example",
+	}
+
+	doc, err := ParseString(strings.Join(input, "\n"))
+	if err != nil {
+		t.Fatalf("ParseString failed: %v", err)
+	}
+
+	sp := NewStreamParser()
+	for i, raw := range input {
+		line := sp.ParseLine(raw)
+		if line.Type != doc.Lines[i].Type {
+			t.Errorf("Line %d: expected %v, got %v", i, doc.Lines[i].Type, line.Type)
+		}
+	}
+
+	// Preformat state must carry across calls
+	if doc.Lines[2].Type != LineTypePreformatted {
+		t.Errorf("Expected link inside preformat block to be preformatted, got %v", doc.Lines[2].Type)
+	}
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// LineParser converts the lines of a line-oriented format into document lines.
+// ParseLine returns nil for lines that produce no output
+type LineParser interface {
+	ParseLine(raw string) *Line
+}
+
+// GopherMenuParser converts gopher menu lines into link and text lines
+// Each menu line is: <type><display>TAB<selector>TAB<host>TAB<port>
+type GopherMenuParser struct{}
+
+// NewGopherMenuParser creates a parser for gopher menus
+func NewGopherMenuParser() *GopherMenuParser {
+	return &GopherMenuParser{}
+}
+
+// ParseLine parses a single gopher menu line
+func (p *GopherMenuParser) ParseLine(raw string) *Line {
+	if raw == "" {
+		return &Line{Type: LineTypeText, Raw: raw}
+	}
+
+	itemType := raw[0]
+	fields := strings.Split(raw[1:], "\t")
+	display := fields[0]
+
+	// Info and error lines, and lines too short to be items, are plain text
+	if itemType == 'i' || itemType == '3' || len(fields) < 3 {
+		return &Line{Type: LineTypeText, Raw: raw, Text: display}
+	}
+
+	selector, host := fields[1], fields[2]
+	port := "70"
+	if len(fields) > 3 {
+		port = strings.TrimSpace(fields[3])
+	}
+
+	link := &LinkInfo{
+		URL:   gopherItemURL(itemType, selector, host, port),
+		Label: display,
+	}
+
+	switch itemType {
+	case '7':
+		link.Label += " [search]"
+	case '9', '5', 's', 'g', 'I', 'p', 'd':
+		link.Label += " [file]"
+	}
+	link.Display = link.Label
+	if link.Display == "" {
+		link.Display = link.URL
+	}
+
+	return &Line{
+		Type: LineTypeLink,
+		Raw:  raw,
+		Text: link.Display,
+		Link: link,
+	}
+}
+
+// gopherItemURL builds the URL of a gopher menu item
+func gopherItemURL(itemType byte, selector, host, port string) string {
+	// "h" items with a "URL:" selector link outside gopherspace
+	if itemType == 'h' && strings.HasPrefix(selector, "URL:") {
+		return strings.TrimPrefix(selector, "URL:")
+	}
+
+	hostPort := host
+	if port != "" && port != "70" {
+		hostPort = net.JoinHostPort(host, port)
+	}
+
+	switch itemType {
+	case '8', 'T':
+		return (&url.URL{Scheme: "telnet", Host: hostPort}).String()
+	}
+
+	u := &url.URL{
+		Scheme: "gopher",
+		Host:   hostPort,
+		Path:   "/" + string(itemType) + selector,
+	}
+	return u.String()
+}
+
+// PlainTextParser converts plain text into preformatted lines so that it is
+// shown exactly as sent, without wrapping or markup
+type PlainTextParser struct{}
+
+// NewPlainTextParser creates a parser for plain text
+func NewPlainTextParser() *PlainTextParser {
+	return &PlainTextParser{}
+}
+
+// ParseLine parses a single line of plain text
+func (p *PlainTextParser) ParseLine(raw string) *Line {
+	return &Line{Type: LineTypePreformatted, Raw: raw, Text: raw}
+}
+
+// ParseLines parses a document from a reader with the given line parser
+func ParseLines(r io.Reader, lp LineParser) (*Document, error) {
+	doc := NewDocument()
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		for _, line := range ExpandLine(lp, scanner.Text()) {
+			doc.AddLine(line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, line := range Flush(lp) {
+		doc.AddLine(line)
+	}
+
+	return doc, nil
+}
+
+// ParseGopherMenu parses a gopher menu into a document
+func ParseGopherMenu(r io.Reader) (*Document, error) {
+	return ParseLines(r, NewGopherMenuParser())
+}
+
+// ParsePlainText parses plain text into a document of preformatted lines
+func ParsePlainText(r io.Reader) (*Document, error) {
+	return ParseLines(r, NewPlainTextParser())
+}
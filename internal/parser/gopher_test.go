@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGopherMenu(t *testing.T) {
+	input := strings.Join([]string{
+		"iWelcome to the server\t\terror.host\t1",
+		"1Phlog\t/phlog\texample.org\t70",
+		"0About\t/about.txt\texample.org\t7070",
+		"7Search\t/search\texample.org\t70",
+		"9Archive\t/files/a.zip\texample.org\t70",
+		"hWeb site\tURL:https://example.com/\texample.org\t70",
+		"3Not found\t\terror.host\t1",
+		"",
+	}, "\n")
+
+	doc, err := ParseGopherMenu(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseGopherMenu failed: %v", err)
+	}
+
+	tests := []struct {
+		lineType LineType
+		text     string
+		url      string
+	}{
+		{LineTypeText, "Welcome to the server", ""},
+		{LineTypeLink, "Phlog", "gopher://example.org/1/phlog"},
+		{LineTypeLink, "About", "gopher://example.org:7070/0/about.txt"},
+		{LineTypeLink, "Search [search]", "gopher://example.org/7/search"},
+		{LineTypeLink, "Archive [file]", "gopher://example.org/9/files/a.zip"},
+		{LineTypeLink, "Web site", "https://example.com/"},
+		{LineTypeText, "Not found", ""},
+	}
+
+	if len(doc.Lines) != len(tests) {
+		t.Fatalf("Expected %d lines, got %d", len(tests), len(doc.Lines))
+	}
+
+	for i, tt := range tests {
+		line := doc.Lines[i]
+		if line.Type != tt.lineType {
+			t.Errorf("Line %d: expected %v, got %v", i, tt.lineType, line.Type)
+		}
+		if line.Text != tt.text {
+			t.Errorf("Line %d: expected text %q, got %q", i, tt.text, line.Text)
+		}
+		if tt.url != "" && line.Link.URL != tt.url {
+			t.Errorf("Line %d: expected URL %q, got %q", i, tt.url, line.Link.URL)
+		}
+	}
+
+	if doc.LinkCount() != 5 {
+		t.Errorf("Expected 5 links, got %d", doc.LinkCount())
+	}
+}
+
+func TestParsePlainText(t *testing.T) {
+	input := "# Not a heading\n=> not a link\n  indented"
+
+	doc, err := ParsePlainText(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParsePlainText failed: %v", err)
+	}
+
+	if len(doc.Lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %d", len(doc.Lines))
+	}
+
+	for i, line := range doc.Lines {
+		if line.Type != LineTypePreformatted {
+			t.Errorf("Line %d: expected LineTypePreformatted, got %v", i, line.Type)
+		}
+		if line.Text != line.Raw {
+			t.Errorf("Line %d: expected text %q, got %q", i, line.Raw, line.Text)
+		}
+	}
+
+	if doc.LinkCount() != 0 {
+		t.Errorf("Expected no links, got %d", doc.LinkCount())
+	}
+}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// markdownLink matches inline links and images: [text](url "title")
+	markdownLink = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+
+	// markdownAutolink matches autolinks: <scheme:...>
+	markdownAutolink = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9+.-]*:[^>\s]+)>`)
+
+	// markdownReference matches link reference definitions: [label]: url
+	markdownReference = regexp.MustCompile(`^\s{0,3}\[([^\]]+)\]:\s*<?(\S+?)>?(?:\s+"[^"]*")?\s*$`)
+
+	// markdownHeading matches ATX headings: # Title
+	markdownHeading = regexp.MustCompile(`^\s{0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+
+	// markdownBullet matches unordered list items: - item
+	markdownBullet = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+
+	// markdownOrdered matches ordered list items: 1. item
+	markdownOrdered = regexp.MustCompile(`^\s*\d+[.)]\s+`)
+)
+
+// MarkdownParser converts Markdown into document lines. Block structure
+// (headings, lists, quotes and code blocks) maps onto gemtext; links inside
+// text are shown as their text, followed by a link line for each of them
+type MarkdownParser struct {
+	// fence is the code fence that opened the current code block ("" if none)
+	fence string
+	alt   string
+}
+
+// NewMarkdownParser creates a parser for Markdown
+func NewMarkdownParser() *MarkdownParser {
+	return &MarkdownParser{}
+}
+
+// ParseLine parses a line of Markdown, returning only its main line.
+// Use ParseLineMulti (or ExpandLine) to also get the links it contains
+func (p *MarkdownParser) ParseLine(raw string) *Line {
+	if lines := p.ParseLineMulti(raw); len(lines) > 0 {
+		return lines[0]
+	}
+	return nil
+}
+
+// ParseLineMulti parses a line of Markdown into its line and link lines
+func (p *MarkdownParser) ParseLineMulti(raw string) []*Line {
+	trimmed := strings.TrimSpace(raw)
+
+	// Code blocks
+	if p.fence != "" {
+		if strings.HasPrefix(trimmed, p.fence) && strings.Trim(trimmed, p.fence[:1]) == "" {
+			alt := p.alt
+			p.fence, p.alt = "", ""
+			return []*Line{{Type: LineTypePreformatToggle, Raw: raw, AltText: alt}}
+		}
+		return []*Line{{Type: LineTypePreformatted, Raw: raw, Text: raw, AltText: p.alt}}
+	}
+	if fence := codeFence(trimmed); fence != "" {
+		p.fence = fence
+		p.alt = strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1]))
+		return []*Line{{Type: LineTypePreformatToggle, Raw: raw, AltText: p.alt}}
+	}
+
+	// Link reference definitions become plain links
+	if m := markdownReference.FindStringSubmatch(raw); m != nil {
+		return []*Line{linkLine(raw, m[2], m[1])}
+	}
+
+	line := &Line{Raw: raw}
+	text := trimmed
+	switch {
+	case markdownHeading.MatchString(raw):
+		m := markdownHeading.FindStringSubmatch(raw)
+		switch len(m[1]) {
+		case 1:
+			line.Type = LineTypeHeading1
+		case 2:
+			line.Type = LineTypeHeading2
+		default:
+			line.Type = LineTypeHeading3
+		}
+		text = m[2]
+
+	case markdownBullet.MatchString(raw) && !isHorizontalRule(trimmed):
+		line.Type = LineTypeListItem
+		text = markdownBullet.FindStringSubmatch(raw)[1]
+
+	case strings.HasPrefix(trimmed, ">"):
+		line.Type = LineTypeQuote
+		text = strings.TrimSpace(strings.TrimLeft(trimmed, "> "))
+
+	case markdownOrdered.MatchString(raw):
+		// Gemtext lists are unordered, so the number is kept in the text
+		line.Type = LineTypeText
+
+	default:
+		line.Type = LineTypeText
+	}
+
+	text, links := markdownInline(text)
+	line.Text = text
+
+	// A line that is only a link is shown as the link alone
+	if line.Type == LineTypeText && len(links) == 1 && text == links[0].Label {
+		return []*Line{linkLine(raw, links[0].URL, links[0].Label)}
+	}
+
+	lines := []*Line{line}
+	for _, link := range links {
+		lines = append(lines, linkLine(raw, link.URL, link.Label))
+	}
+	return lines
+}
+
+// codeFence returns the fence (``` or ~~~) that a line opens a code block with
+func codeFence(trimmed string) string {
+	for _, fence := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, fence) {
+			return fence
+		}
+	}
+	return ""
+}
+
+// isHorizontalRule reports whether a line is a thematic break such as "* * *"
+func isHorizontalRule(trimmed string) bool {
+	rule := strings.ReplaceAll(trimmed, " ", "")
+	if len(rule) < 3 {
+		return false
+	}
+	return strings.Trim(rule, rule[:1]) == "" && strings.ContainsAny(rule[:1], "-*_")
+}
+
+// markdownInline strips inline markup from text, returning the links it held
+func markdownInline(text string) (string, []*LinkInfo) {
+	var links []*LinkInfo
+
+	text = markdownLink.ReplaceAllStringFunc(text, func(s string) string {
+		m := markdownLink.FindStringSubmatch(s)
+		label := m[2]
+		if m[1] == "!" {
+			if label == "" {
+				label = "image"
+			}
+			label += " [image]"
+		}
+		links = append(links, &LinkInfo{URL: m[3], Label: label})
+		return m[2]
+	})
+
+	text = markdownAutolink.ReplaceAllStringFunc(text, func(s string) string {
+		target := markdownAutolink.FindStringSubmatch(s)[1]
+		links = append(links, &LinkInfo{URL: target, Label: target})
+		return target
+	})
+
+	// Strong emphasis and code spans lose their markers
+	text = strings.NewReplacer("**", "", "__", "", "`", "").Replace(text)
+
+	return strings.TrimSpace(text), links
+}
+
+// linkLine builds a link line
+func linkLine(raw, target, label string) *Line {
+	link := &LinkInfo{URL: target, Label: label, Display: label}
+	if link.Display == "" {
+		link.Display = target
+	}
+	return &Line{Type: LineTypeLink, Raw: raw, Text: link.Display, Link: link}
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	input := strings.Join([]string{
+		"# Title #",
+		"Some **bold** text with a [link](gemini://example.org/) and `code`.",
+		"",
+		"[Only a link](/page)",
+		"- item one",
+		"2. second",
+		"> quoted",
+		"#### Deep",
+		"```go",
+		"# not a heading",
+		"```",
+		"![A cat](cat.png)",
+		"[ref]: https://example.com/ref",
+		"---",
+	}, "\n")
+
+	doc, err := ParseLines(strings.NewReader(input), NewMarkdownParser())
+	if err != nil {
+		t.Fatalf("ParseLines failed: %v", err)
+	}
+
+	tests := []struct {
+		lineType LineType
+		text     string
+		url      string
+	}{
+		{LineTypeHeading1, "Title", ""},
+		{LineTypeText, "Some bold text with a link and code.", ""},
+		{LineTypeLink, "link", "gemini://example.org/"},
+		{LineTypeText, "", ""},
+		{LineTypeLink, "Only a link", "/page"},
+		{LineTypeListItem, "item one", ""},
+		{LineTypeText, "2. second", ""},
+		{LineTypeQuote, "quoted", ""},
+		{LineTypeHeading3, "Deep", ""},
+		{LineTypePreformatToggle, "", ""},
+		{LineTypePreformatted, "# not a heading", ""},
+		{LineTypePreformatToggle, "", ""},
+		{LineTypeText, "A cat", ""},
+		{LineTypeLink, "A cat [image]", "cat.png"},
+		{LineTypeLink, "ref", "https://example.com/ref"},
+		{LineTypeText, "---", ""},
+	}
+
+	if len(doc.Lines) != len(tests) {
+		for _, line := range doc.Lines {
+			t.Logf("%s %q", line.Type, line.Text)
+		}
+		t.Fatalf("Expected %d lines, got %d", len(tests), len(doc.Lines))
+	}
+
+	for i, tt := range tests {
+		line := doc.Lines[i]
+		if line.Type != tt.lineType || line.Text != tt.text {
+			t.Errorf("Line %d: expected %s %q, got %s %q", i, tt.lineType, tt.text, line.Type, line.Text)
+		}
+		if tt.url != "" && (line.Link == nil || line.Link.URL != tt.url) {
+			t.Errorf("Line %d: expected link to %q, got %+v", i, tt.url, line.Link)
+		}
+	}
+
+	if alt := doc.Lines[9].AltText; alt != "go" {
+		t.Errorf("Expected code block alt text %q, got %q", "go", alt)
+	}
+}
@@ -0,0 +1,90 @@
+package parser
+
+import "strings"
+
+// MultiLineParser is implemented by line parsers that can turn one input
+// line into several document lines, such as a Markdown paragraph followed
+// by the links it contains
+type MultiLineParser interface {
+	ParseLineMulti(raw string) []*Line
+}
+
+// Flusher is implemented by line parsers that hold lines back until the
+// whole input has been read, such as the feed parser
+type Flusher interface {
+	Flush() []*Line
+}
+
+// ExpandLine returns the document lines lp produces for one input line
+func ExpandLine(lp LineParser, raw string) []*Line {
+	if mp, ok := lp.(MultiLineParser); ok {
+		return mp.ParseLineMulti(raw)
+	}
+	if line := lp.ParseLine(raw); line != nil {
+		return []*Line{line}
+	}
+	return nil
+}
+
+// Flush returns the lines lp held back, once its input has ended
+func Flush(lp LineParser) []*Line {
+	if f, ok := lp.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// ParserFactory creates a line parser for one document
+type ParserFactory func() LineParser
+
+// Registry chooses the parser that turns a body of a given MIME type into
+// a document. Types are registered by exact type ("text/markdown") or by
+// pattern ("text/*"); exact registrations take precedence
+type Registry struct {
+	parsers map[string]ParserFactory
+}
+
+// NewRegistry creates a registry of the parsers for the types shown as
+// documents: gemtext, Markdown, Atom feeds and, as plain text, other text
+func NewRegistry() *Registry {
+	r := &Registry{parsers: make(map[string]ParserFactory)}
+	r.Register("text/gemini", func() LineParser { return NewStreamParser() })
+	r.Register("text/markdown", func() LineParser { return NewMarkdownParser() })
+	r.Register("text/x-markdown", func() LineParser { return NewMarkdownParser() })
+	r.Register("application/atom+xml", func() LineParser { return NewFeedParser() })
+	r.Register("text/*", func() LineParser { return NewPlainTextParser() })
+	return r
+}
+
+// Register sets the parser for a MIME type or pattern
+func (r *Registry) Register(pattern string, factory ParserFactory) {
+	r.parsers[strings.ToLower(pattern)] = factory
+}
+
+// Lookup returns a new parser for a MIME type, or false if the type is not
+// shown as a document
+func (r *Registry) Lookup(mimeType string) (LineParser, bool) {
+	mimeType = strings.ToLower(mimeType)
+	if factory, ok := r.parsers[mimeType]; ok {
+		return factory(), true
+	}
+	if major, _, ok := strings.Cut(mimeType, "/"); ok {
+		if factory, ok := r.parsers[major+"/*"]; ok {
+			return factory(), true
+		}
+	}
+	return nil, false
+}
+
+// MatchMIME reports whether a MIME type matches a pattern, which is either
+// a type ("image/png"), a type with any subtype ("image/*") or "*"
+func MatchMIME(pattern, mimeType string) bool {
+	pattern, mimeType = strings.ToLower(pattern), strings.ToLower(mimeType)
+	switch {
+	case pattern == "*" || pattern == "*/*":
+		return true
+	case strings.HasSuffix(pattern, "/*"):
+		return strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == mimeType
+}
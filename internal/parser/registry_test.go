@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRegistryLookup(t *testing.T) {
+	r := NewRegistry()
+
+	tests := []struct {
+		mimeType string
+		parser   string
+	}{
+		{"text/gemini", "*parser.StreamParser"},
+		{"text/markdown", "*parser.MarkdownParser"},
+		{"application/atom+xml", "*parser.FeedParser"},
+		{"text/plain", "*parser.PlainTextParser"},
+		{"TEXT/CSV", "*parser.PlainTextParser"},
+		{"image/png", ""},
+		{"application/pdf", ""},
+	}
+
+	for _, tt := range tests {
+		lp, ok := r.Lookup(tt.mimeType)
+		got := ""
+		if ok {
+			got = fmt.Sprintf("%T", lp)
+		}
+		if got != tt.parser {
+			t.Errorf("Lookup(%q) = %q, expected %q", tt.mimeType, got, tt.parser)
+		}
+	}
+
+	// Exact registrations win over patterns
+	r.Register("text/csv", func() LineParser { return NewStreamParser() })
+	if lp, _ := r.Lookup("text/csv"); fmt.Sprintf("%T", lp) != "*parser.StreamParser" {
+		t.Errorf("Expected registered parser for text/csv, got %s", fmt.Sprintf("%T", lp))
+	}
+}
+
+func TestMatchMIME(t *testing.T) {
+	tests := []struct {
+		pattern, mimeType string
+		match             bool
+	}{
+		{"image/*", "image/png", true},
+		{"image/*", "imagex/png", false},
+		{"application/pdf", "application/pdf", true},
+		{"application/pdf", "application/pdfx", false},
+		{"*", "audio/ogg", true},
+		{"Audio/*", "audio/ogg", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchMIME(tt.pattern, tt.mimeType); got != tt.match {
+			t.Errorf("MatchMIME(%q, %q) = %v, expected %v", tt.pattern, tt.mimeType, got, tt.match)
+		}
+	}
+}
@@ -142,18 +142,25 @@ func wrapText(text string, width int, indent string) []string {
 
 // Render renders a document to a string
 func (r *Renderer) Render(doc *Document) string {
+	rendered, _ := r.RenderFrom(doc, 0, 0)
+	return rendered
+}
+
+// RenderFrom renders the lines of a document from index start on, to be
+// appended to the rendering of the lines before it. links is the number of
+// links before start; the number of links up to the end is returned
+func (r *Renderer) RenderFrom(doc *Document, start, links int) (string, int) {
 	var b strings.Builder
-	linkIndex := 0
+	linkIndex := links
 
-	for i, line := range doc.Lines {
-		rendered := r.renderLine(line, i, &linkIndex)
-		b.WriteString(rendered)
-		if i < len(doc.Lines)-1 {
+	for i := start; i < len(doc.Lines); i++ {
+		if i > 0 {
 			b.WriteString("\n")
 		}
+		b.WriteString(r.renderLine(doc.Lines[i], i, &linkIndex))
 	}
 
-	return b.String()
+	return b.String(), linkIndex
 }
 
 // renderLine renders a single line, with optional text wrapping
@@ -176,7 +183,7 @@ func (r *Renderer) renderLine(line *Line, lineNum int, linkIndex *int) string {
 	case LineTypeHeading3:
 		return r.renderWrappedLine(prefix+cs.Heading3+"### "+cs.Reset, line.Text, cs.Heading3, cs.Reset, "    ")
 
-	case LineTypeLink:
+	case LineTypeLink, LineTypePromptLink:
 		linkNum := *linkIndex
 		*linkIndex++
 
@@ -192,6 +199,11 @@ func (r *Renderer) renderLine(line *Line, lineNum int, linkIndex *int) string {
 			linkLabel = fmt.Sprintf("[%d] ", linkNum+1)
 		}
 
+		// Prompt links ask for input before they are followed
+		if line.Type == LineTypePromptLink {
+			linkLabel += "? "
+		}
+
 		linkPrefix := prefix + style + linkLabel
 		// Calculate indent width (without ANSI codes)
 		indentWidth := len(prefix) + len(linkLabel)
@@ -312,7 +324,7 @@ func GetLinkAtLine(doc *Document, lineNum int) int {
 	}
 
 	line := doc.Lines[lineNum]
-	if line.Type != LineTypeLink {
+	if !line.Type.IsLink() {
 		return -1
 	}
 
@@ -116,6 +116,33 @@ func TestRenderWrapping(t *testing.T) {
 	}
 }
 
+func TestRenderFrom(t *testing.T) {
+	doc, err := ParseString("# Title\n=> gemini://a.example/ A\nText\n=> gemini://b.example/ B\n* Item\n=> gemini://c.example/ C")
+	if err != nil {
+		t.Fatalf("ParseString failed: %v", err)
+	}
+
+	renderer := NewRenderer(&RenderOptions{
+		Width:           40,
+		NumberLinks:     true,
+		HighlightedLink: 2,
+		ColorScheme:     &ColorScheme{},
+	})
+	full := renderer.Render(doc)
+
+	// Rendering in pieces and appending gives the same text and link numbers
+	for split := 0; split <= len(doc.Lines); split++ {
+		head, links := renderer.RenderFrom(&Document{Lines: doc.Lines[:split]}, 0, 0)
+		tail, total := renderer.RenderFrom(doc, split, links)
+		if head+tail != full {
+			t.Errorf("Split at %d: expected %q, got %q", split, full, head+tail)
+		}
+		if total != 3 {
+			t.Errorf("Split at %d: expected 3 links, got %d", split, total)
+		}
+	}
+}
+
 func TestStripANSI(t *testing.T) {
 	tests := []struct {
 		name     string
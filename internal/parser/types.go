@@ -30,6 +30,9 @@ const (
 
 	// LineTypePreformatToggle is the toggle line itself (```)
 	LineTypePreformatToggle
+
+	// LineTypePromptLink is a Spartan prompt link (=:), whose target expects input
+	LineTypePromptLink
 )
 
 // String returns a string representation of the line type
@@ -53,11 +56,18 @@ func (t LineType) String() string {
 		return "Preformatted"
 	case LineTypePreformatToggle:
 		return "PreformatToggle"
+	case LineTypePromptLink:
+		return "PromptLink"
 	default:
 		return "Unknown"
 	}
 }
 
+// IsLink returns true if the line type is a link or prompt link
+func (t LineType) IsLink() bool {
+	return t == LineTypeLink || t == LineTypePromptLink
+}
+
 // IsHeading returns true if the line type is a heading
 func (t LineType) IsHeading() bool {
 	return t == LineTypeHeading1 || t == LineTypeHeading2 || t == LineTypeHeading3
@@ -104,6 +114,10 @@ type Document struct {
 
 	// Headings contains all headings in the document (for TOC generation)
 	Headings []*Line
+
+	// Lang is the language of the document from the lang parameter of its
+	// MIME type (e.g. "en", "fr-CA"), or empty if it was not given
+	Lang string
 }
 
 // NewDocument creates a new empty document
@@ -120,7 +134,7 @@ func (d *Document) AddLine(line *Line) {
 	d.Lines = append(d.Lines, line)
 
 	// Add to links collection if it's a link
-	if line.Type == LineTypeLink {
+	if line.Type.IsLink() {
 		d.Links = append(d.Links, line)
 	}
 
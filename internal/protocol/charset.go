@@ -0,0 +1,28 @@
+package protocol
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// DefaultCharset is the charset of text responses that do not declare one
+const DefaultCharset = "utf-8"
+
+// NewTextReader returns a reader that decodes body from charset to UTF-8.
+// Bodies that are already UTF-8 (or US-ASCII) are returned unchanged
+func NewTextReader(body io.Reader, charset string) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return body, nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return transform.NewReader(body, enc.NewDecoder()), nil
+}
@@ -0,0 +1,70 @@
+package protocol
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestResponseParams(t *testing.T) {
+	resp := &Response{Status: StatusSuccess, Meta: "text/gemini; charset=Shift_JIS; lang=ja"}
+
+	if got := resp.MIMEType(); got != "text/gemini" {
+		t.Errorf("Expected MIME type text/gemini, got %q", got)
+	}
+	if got := resp.Charset(); got != "shift_jis" {
+		t.Errorf("Expected charset shift_jis, got %q", got)
+	}
+	if got := resp.Lang(); got != "ja" {
+		t.Errorf("Expected lang ja, got %q", got)
+	}
+
+	resp = &Response{Status: StatusSuccess, Meta: "text/gemini"}
+	if got := resp.Charset(); got != DefaultCharset {
+		t.Errorf("Expected default charset, got %q", got)
+	}
+	if got := resp.Lang(); got != "" {
+		t.Errorf("Expected no lang, got %q", got)
+	}
+
+	resp = &Response{Status: StatusSuccess, Meta: "image/png"}
+	if got := resp.Charset(); got != "" {
+		t.Errorf("Expected no charset for an image, got %q", got)
+	}
+
+	resp = &Response{Status: StatusNotFound, Meta: "text/gemini; lang=en"}
+	if resp.Params() != nil {
+		t.Error("Expected no parameters for a failure response")
+	}
+}
+
+func TestNewTextReader(t *testing.T) {
+	tests := []struct {
+		charset string
+		body    string
+		want    string
+	}{
+		{"", "caf\xc3\xa9", "café"},
+		{"utf-8", "caf\xc3\xa9", "café"},
+		{"iso-8859-1", "caf\xe9", "café"},
+		{"shift_jis", "\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd", "こんにちは"},
+	}
+
+	for _, tt := range tests {
+		r, err := NewTextReader(strings.NewReader(tt.body), tt.charset)
+		if err != nil {
+			t.Fatalf("NewTextReader(%q) failed: %v", tt.charset, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("reading %q text failed: %v", tt.charset, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Decoding %q: expected %q, got %q", tt.charset, tt.want, got)
+		}
+	}
+
+	if _, err := NewTextReader(strings.NewReader(""), "no-such-charset"); err == nil {
+		t.Error("Expected error for an unknown charset")
+	}
+}
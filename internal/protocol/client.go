@@ -1,11 +1,14 @@
 package protocol
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 )
@@ -17,15 +20,49 @@ const (
 	// DefaultTimeout is the default connection timeout
 	DefaultTimeout = 30 * time.Second
 
+	// DefaultHandshakeTimeout is the default TLS handshake timeout
+	DefaultHandshakeTimeout = 30 * time.Second
+
+	// DefaultHeaderTimeout is the default time allowed to send the request
+	// and receive the response header
+	DefaultHeaderTimeout = 30 * time.Second
+
 	// MaxRedirects is the maximum number of redirects to follow
 	MaxRedirects = 5
 )
 
+// Errors reported when a request exceeds one of the client's limits.
+// They are wrapped, so use errors.Is to check for them
+var (
+	ErrConnectTimeout   = errors.New("timed out connecting")
+	ErrHandshakeTimeout = errors.New("timed out during TLS handshake")
+	ErrHeaderTimeout    = errors.New("timed out waiting for response header")
+	ErrBodyTimeout      = errors.New("timed out waiting for response body")
+	ErrTotalTimeout     = errors.New("request exceeded its total time limit")
+	ErrBodyTooLarge     = errors.New("response body exceeds size limit")
+)
+
 // Client is a Gemini protocol client
 type Client struct {
 	// Timeout is the connection timeout
 	Timeout time.Duration
 
+	// HandshakeTimeout limits the TLS handshake (0 = no limit)
+	HandshakeTimeout time.Duration
+
+	// HeaderTimeout limits sending the request and reading the response header (0 = no limit)
+	HeaderTimeout time.Duration
+
+	// BodyIdleTimeout limits how long a body read may wait for data (0 = no limit)
+	// Streams that legitimately pause, such as live logs, need this unset or generous
+	BodyIdleTimeout time.Duration
+
+	// TotalTimeout limits the whole request, including redirects and reading the body (0 = no limit)
+	TotalTimeout time.Duration
+
+	// MaxBodySize is the maximum number of body bytes that will be read (0 = no limit)
+	MaxBodySize int64
+
 	// TLSConfig is the TLS configuration
 	// If nil, a default config will be used
 	TLSConfig *tls.Config
@@ -36,16 +73,40 @@ type Client struct {
 	// MaxRedirects is the maximum number of redirects to follow
 	MaxRedirects int
 
+	// OnRedirect is called before following a redirect to another host or
+	// scheme. It should return true to follow it, false to stop and return
+	// the redirect response. If nil, all redirects are followed
+	OnRedirect func(from, to string, permanent bool) bool
+
+	// Backoff tracks hosts that answered 44 SLOW DOWN; requests to them fail
+	// with a *SlowDownError until their wait has passed. If nil, nothing is tracked
+	Backoff *Backoff
+
+	// RetrySlowDown is the longest 44 SLOW DOWN wait after which a request is
+	// retried automatically (0 = never retry, return the 44 response)
+	RetrySlowDown time.Duration
+
 	// TOFU is the Trust On First Use certificate verifier
 	TOFU *TOFUVerifier
+
+	// Identities holds client certificates to present for matching URLs
+	// If nil, no client certificate is ever sent
+	Identities *IdentityStore
+
+	// Proxies maps a URL scheme to the host[:port] of a Gemini proxy.
+	// Requests for URLs with that scheme are sent, as full URLs, to the proxy
+	Proxies map[string]string
 }
 
 // NewClient creates a new Gemini client with default settings
 func NewClient() *Client {
 	return &Client{
-		Timeout:         DefaultTimeout,
-		FollowRedirects: true,
-		MaxRedirects:    MaxRedirects,
+		Timeout:          DefaultTimeout,
+		HandshakeTimeout: DefaultHandshakeTimeout,
+		HeaderTimeout:    DefaultHeaderTimeout,
+		FollowRedirects:  true,
+		MaxRedirects:     MaxRedirects,
+		Backoff:          NewBackoff(),
 		TLSConfig: &tls.Config{
 			MinVersion: tls.VersionTLS12,
 			// InsecureSkipVerify is set to true because we do TOFU verification
@@ -57,111 +118,397 @@ func NewClient() *Client {
 
 // Get performs a GET request to the specified URL
 func (c *Client) Get(rawURL string) (*Response, error) {
-	return c.get(rawURL, 0)
+	return c.GetContext(context.Background(), rawURL)
+}
+
+// GetContext performs a GET request to the specified URL.
+// Cancelling ctx aborts dialing, the TLS handshake and reading the response
+// header; for success responses it also aborts reads from the body
+func (c *Client) GetContext(ctx context.Context, rawURL string) (*Response, error) {
+	if c.TotalTimeout <= 0 {
+		return c.get(ctx, rawURL, nil)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, c.TotalTimeout, ErrTotalTimeout)
+	resp, err := c.get(ctx, rawURL, nil)
+	if err != nil || resp.Body == nil {
+		cancel()
+		return resp, err
+	}
+
+	// The deadline keeps running while the body is read
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases a request's context once its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
 }
 
-// get is the internal implementation that tracks redirect count
-func (c *Client) get(rawURL string, redirectCount int) (*Response, error) {
+// Close closes the body and releases the context
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// get is the internal implementation that follows redirects, recording
+// the redirects taken so far on the final response
+func (c *Client) get(ctx context.Context, rawURL string, redirects []Redirect) (*Response, error) {
+	resp, err := c.fetchPolitely(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	resp.Redirects = redirects
+
+	if !resp.Status.IsRedirect() || !c.FollowRedirects {
+		return resp, nil
+	}
+
+	// Check redirect limit
+	if len(redirects) >= c.MaxRedirects {
+		return nil, fmt.Errorf("too many redirects (max %d)", c.MaxRedirects)
+	}
+
+	// Parse redirect URL
+	if resp.Meta == "" {
+		return nil, fmt.Errorf("redirect without URL")
+	}
+
+	// Resolve relative URLs
+	redirectURL, err := resolveURL(rawURL, resp.Meta)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect URL: %w", err)
+	}
+
+	redirect := Redirect{
+		URL:       rawURL,
+		Target:    redirectURL,
+		Permanent: resp.Status == StatusRedirectPermanent,
+	}
+	if redirectLoops(redirects, redirect) {
+		return nil, fmt.Errorf("redirect loop at %s", redirectURL)
+	}
+
+	// A remote page must never make the client read local files or open
+	// internal pages, so redirects only go where the network can reach
+	if u, err := url.Parse(redirectURL); err != nil || !c.redirectable(u) {
+		return nil, fmt.Errorf("refusing redirect to %s", redirectURL)
+	}
+
+	// Leaving the host or scheme needs the caller's approval
+	if c.OnRedirect != nil && !sameOrigin(rawURL, redirectURL) {
+		if !c.OnRedirect(rawURL, redirectURL, redirect.Permanent) {
+			resp.Meta = redirectURL
+			return resp, nil
+		}
+	}
+
+	// Follow redirect
+	return c.get(ctx, redirectURL, append(redirects[:len(redirects):len(redirects)], redirect))
+}
+
+// redirectable reports whether a redirect may lead to u: only schemes the
+// client fetches over the network, natively or through a proxy
+func (c *Client) redirectable(u *url.URL) bool {
+	switch u.Scheme {
+	case "gemini", "gopher", "spartan", "finger", "nex":
+		return true
+	case "file", "about":
+		return false
+	}
+	return c.Proxies[u.Scheme] != ""
+}
+
+// fetch performs a single request, without following redirects
+func (c *Client) fetch(ctx context.Context, rawURL string) (*Response, error) {
 	// Parse URL
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 
-	// Validate URL scheme
+	// Other schemes are sent to a configured proxy, or fetched natively
+	// if the client speaks their protocol
+	proxy := ""
 	if u.Scheme != "gemini" {
-		return nil, fmt.Errorf("unsupported URL scheme: %s (expected gemini)", u.Scheme)
+		proxy = c.Proxies[u.Scheme]
+		if proxy == "" {
+			switch u.Scheme {
+			case "gopher":
+				return c.getGopher(ctx, u)
+			case "spartan":
+				return c.getSpartan(ctx, u)
+			case "finger":
+				return c.getFinger(ctx, u)
+			case "nex":
+				return c.getNex(ctx, u)
+			case "file":
+				return getFile(u)
+			}
+			return nil, fmt.Errorf("unsupported URL scheme: %s (expected gemini, or configure a proxy)", u.Scheme)
+		}
 	}
 
 	// Get host and port
 	host := u.Host
+	if proxy != "" {
+		host = proxy
+	}
 	if !strings.Contains(host, ":") {
 		host = net.JoinHostPort(host, DefaultPort)
 	}
 
 	// Create TLS connection
-	dialer := &net.Dialer{
-		Timeout: c.Timeout,
-	}
-
-	conn, err := tls.DialWithDialer(dialer, "tcp", host, c.TLSConfig)
+	conn, closeConn, err := c.dial(ctx, host, rawURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to %s: %w", host, err)
+		return nil, err
 	}
-	defer func() {
-		// We'll only close the connection if there's an error
-		// For success responses, the caller is responsible for closing
-		if err != nil {
-			conn.Close()
-		}
-	}()
 
-	// Verify certificate with TOFU if available
-	if c.TOFU != nil {
-		hostname, _, _ := net.SplitHostPort(host)
-		if hostname == "" {
-			hostname = host
-		}
-
-		if err := c.TOFU.VerifyCertificate(hostname, conn.ConnectionState()); err != nil {
-			return nil, fmt.Errorf("certificate verification failed: %w", err)
-		}
+	// The header deadline covers sending the request and reading the reply
+	if c.HeaderTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.HeaderTimeout))
 	}
 
 	// Send request
 	request := rawURL + "\r\n"
 	if _, err := io.WriteString(conn, request); err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		closeConn()
+		return nil, fmt.Errorf("failed to send request: %w", timeoutError(ctx, err, ErrHeaderTimeout))
 	}
 
 	// Read response
 	resp, err := ReadResponse(conn, rawURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		closeConn()
+		return nil, fmt.Errorf("failed to read response: %w", timeoutError(ctx, err, ErrHeaderTimeout))
 	}
+	conn.SetDeadline(time.Time{})
+	resp.Proxy = proxy
 
 	// Store TLS state
-	// resp.TLSState = &conn.ConnectionState()
+	state := conn.ConnectionState()
+	resp.TLSState = &state
 
-	// Handle redirects
-	if resp.Status.IsRedirect() {
-		// Close the connection since we won't be reading the body
-		conn.Close()
+	// For non-success responses, including redirects, close the connection
+	if !resp.Status.IsSuccess() {
+		closeConn()
+		return resp, nil
+	}
 
-		// Check if we should follow redirects
-		if !c.FollowRedirects {
-			return resp, nil
-		}
+	c.attachBody(ctx, resp, conn, closeConn)
+	return resp, nil
+}
 
-		// Check redirect limit
-		if redirectCount >= c.MaxRedirects {
-			return nil, fmt.Errorf("too many redirects (max %d)", c.MaxRedirects)
-		}
+// dial opens a TLS connection to host (host:port) for a request to rawURL
+// and verifies the server certificate with TOFU.
+// The returned function closes the connection; it is also closed if ctx
+// is cancelled, which unblocks any pending read or write
+func (c *Client) dial(ctx context.Context, host, rawURL string) (*tls.Conn, func(), error) {
+	hostname, _, _ := net.SplitHostPort(host)
 
-		// Parse redirect URL
-		redirectURL := resp.Meta
-		if redirectURL == "" {
-			return nil, fmt.Errorf("redirect without URL")
-		}
+	rawConn, closeConn, err := c.dialTCP(ctx, host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn := tls.Client(rawConn, c.tlsConfigFor(rawURL, hostname))
+	if err := c.handshake(ctx, conn); err != nil {
+		closeConn()
+		return nil, nil, fmt.Errorf("failed to connect to %s: %w", host, err)
+	}
 
-		// Resolve relative URLs
-		redirectURL, err = resolveURL(rawURL, redirectURL)
-		if err != nil {
-			return nil, fmt.Errorf("invalid redirect URL: %w", err)
+	// Verify certificate with TOFU if available
+	if c.TOFU != nil {
+		if err := c.TOFU.VerifyCertificate(host, conn.ConnectionState()); err != nil {
+			closeConn()
+			return nil, nil, fmt.Errorf("certificate verification failed: %w", err)
 		}
+	}
+
+	return conn, closeConn, nil
+}
 
-		// Follow redirect
-		return c.get(redirectURL, redirectCount+1)
+// dialTCP opens a plain TCP connection to host (host:port).
+// The returned function closes the connection; it is also closed if ctx
+// is cancelled, which unblocks any pending read or write
+func (c *Client) dialTCP(ctx context.Context, host string) (net.Conn, func(), error) {
+	dialer := &net.Dialer{
+		Timeout: c.Timeout,
 	}
 
-	// For non-success, non-redirect responses, close the connection
-	if !resp.Status.IsSuccess() {
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to %s: %w", host, timeoutError(ctx, err, ErrConnectTimeout))
+	}
+
+	stop := context.AfterFunc(ctx, func() {
 		conn.Close()
+	})
+	closeConn := func() {
+		stop()
+		conn.Close()
+	}
+
+	return conn, closeConn, nil
+}
+
+// fetchTCP sends a one-line request over plain TCP and returns the reply,
+// which has no header, as a success response of the given MIME type.
+// This is the shape of the Gopher, Finger and Nex protocols
+func (c *Client) fetchTCP(ctx context.Context, rawURL, host, request, mimeType string) (*Response, error) {
+	conn, closeConn, err := c.dialTCP(ctx, host)
+	if err != nil {
+		return nil, err
 	}
 
+	if c.HeaderTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(c.HeaderTimeout))
+	}
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		closeConn()
+		return nil, fmt.Errorf("failed to send request: %w", timeoutError(ctx, err, ErrHeaderTimeout))
+	}
+	conn.SetWriteDeadline(time.Time{})
+
+	resp := &Response{
+		Status: StatusSuccess,
+		Meta:   mimeType,
+		URL:    rawURL,
+		Body:   io.NopCloser(conn),
+	}
+	c.attachBody(ctx, resp, conn, closeConn)
 	return resp, nil
 }
 
+// attachBody makes a success response's body own its connection, applying
+// the client's body limits. The caller closes the connection by closing the body
+func (c *Client) attachBody(ctx context.Context, resp *Response, conn net.Conn, closeConn func()) {
+	resp.Body = &responseBody{
+		Reader:      resp.Body,
+		ctx:         ctx,
+		conn:        conn,
+		close:       closeConn,
+		idleTimeout: c.BodyIdleTimeout,
+		limited:     c.MaxBodySize > 0,
+		remaining:   c.MaxBodySize,
+	}
+}
+
+// handshake performs the TLS handshake within the client's handshake timeout
+func (c *Client) handshake(ctx context.Context, conn *tls.Conn) error {
+	if c.HandshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, c.HandshakeTimeout, ErrHandshakeTimeout)
+		defer cancel()
+	}
+
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return contextError(ctx, err)
+	}
+	return nil
+}
+
+// responseBody is a success response body that owns the connection it is read from
+type responseBody struct {
+	io.Reader
+	ctx   context.Context
+	conn  net.Conn
+	close func()
+
+	// idleTimeout is the deadline applied to each read (0 = none)
+	idleTimeout time.Duration
+
+	// remaining is the number of bytes that may still be read if limited
+	limited   bool
+	remaining int64
+}
+
+// Read reads from the body, enforcing the idle timeout and size limit and
+// reporting the context error if the read was interrupted by cancellation
+func (b *responseBody) Read(p []byte) (int, error) {
+	if b.limited {
+		// Read one byte past the limit to tell a body of exactly the
+		// maximum size from one that is too large
+		if int64(len(p)) > b.remaining+1 {
+			p = p[:b.remaining+1]
+		}
+	}
+
+	if b.idleTimeout > 0 {
+		b.conn.SetReadDeadline(time.Now().Add(b.idleTimeout))
+	}
+
+	n, err := b.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = timeoutError(b.ctx, err, ErrBodyTimeout)
+	}
+
+	if b.limited {
+		if int64(n) > b.remaining {
+			n = int(b.remaining)
+			err = ErrBodyTooLarge
+		}
+		b.remaining -= int64(n)
+	}
+
+	return n, err
+}
+
+// Close closes the underlying connection
+func (b *responseBody) Close() error {
+	b.close()
+	return nil
+}
+
+// contextError returns the cause of the context's cancellation if it is done,
+// so that callers see context.Canceled or ErrTotalTimeout rather than a
+// closed connection error
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// timeoutError is like contextError, but also reports a network timeout
+// (an expired connection deadline) as phaseErr
+func timeoutError(ctx context.Context, err error, phaseErr error) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	var netErr net.Error
+	if errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return phaseErr
+	}
+	return err
+}
+
+// tlsConfigFor returns the TLS configuration for a request to rawURL on
+// hostname, including the client certificate of any identity scoped to it
+func (c *Client) tlsConfigFor(rawURL, hostname string) *tls.Config {
+	config := &tls.Config{}
+	if c.TLSConfig != nil {
+		config = c.TLSConfig.Clone()
+	}
+
+	// Send SNI so that virtual-hosted capsules serve the right certificate
+	if config.ServerName == "" {
+		config.ServerName = hostname
+	}
+
+	if c.Identities != nil {
+		if id := c.Identities.ForURL(rawURL); id != nil {
+			config.Certificates = []tls.Certificate{id.Certificate}
+		}
+	}
+
+	return config
+}
+
 // resolveURL resolves a potentially relative URL against a base URL
 func resolveURL(base, ref string) (string, error) {
 	baseURL, err := url.Parse(base)
@@ -180,12 +527,19 @@ func resolveURL(base, ref string) (string, error) {
 // Request performs a request with the given URL and options
 // This is a more flexible alternative to Get
 func (c *Client) Request(rawURL string, opts ...RequestOption) (*Response, error) {
-	// Apply options
+	return c.RequestContext(context.Background(), rawURL, opts...)
+}
+
+// RequestContext performs a cancellable request with the given URL and options.
+// The options apply to this request only and do not modify the client
+func (c *Client) RequestContext(ctx context.Context, rawURL string, opts ...RequestOption) (*Response, error) {
+	// Apply options to a copy of the client
+	rc := *c
 	for _, opt := range opts {
-		opt(c)
+		opt(&rc)
 	}
 
-	return c.Get(rawURL)
+	return rc.GetContext(ctx, rawURL)
 }
 
 // RequestOption is a function that configures a request
@@ -0,0 +1,253 @@
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTestServer runs a TLS server on localhost that calls handler with
+// each connection and request line, returning the server address
+func startTestServer(t *testing.T, handler func(conn net.Conn, request string)) string {
+	t.Helper()
+
+	id, err := GenerateIdentity("localhost", IdentityOptions{KeyType: KeyECDSA})
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{id.Certificate},
+	})
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				request, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				handler(&bufferedConn{Conn: conn, r: r}, strings.TrimRight(request, "\r\n"))
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// bufferedConn is a connection whose reads continue from a bufio.Reader
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func TestClientGet(t *testing.T) {
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		io.WriteString(conn, "20 text/gemini\r\n# Hello\r\n"+request+"\r\n")
+	})
+
+	resp, err := NewClient().Get("gemini://" + addr + "/page")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	if resp.TLSState == nil || len(resp.TLSState.PeerCertificates) == 0 {
+		t.Error("Expected TLS state with the server certificate")
+	}
+
+	body, err := resp.ReadBody()
+	if err != nil {
+		t.Fatalf("ReadBody failed: %v", err)
+	}
+
+	expected := "# Hello\r\ngemini://" + addr + "/page\r\n"
+	if string(body) != expected {
+		t.Errorf("Expected body %q, got %q", expected, string(body))
+	}
+}
+
+func TestClientHeaderTimeout(t *testing.T) {
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		time.Sleep(time.Second)
+	})
+
+	client := NewClient()
+	client.HeaderTimeout = 50 * time.Millisecond
+
+	_, err := client.Get("gemini://" + addr + "/")
+	if !errors.Is(err, ErrHeaderTimeout) {
+		t.Errorf("Expected ErrHeaderTimeout, got %v", err)
+	}
+}
+
+func TestClientCancelDuringHeader(t *testing.T) {
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		time.Sleep(5 * time.Second)
+	})
+
+	client := NewClient()
+	client.HeaderTimeout = 10 * time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.GetContext(ctx, "gemini://"+addr+"/")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected cancel to stop the request promptly, took %s", elapsed)
+	}
+}
+
+func TestClientBodyLimits(t *testing.T) {
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		io.WriteString(conn, "20 text/plain\r\n")
+		switch request {
+		case "gemini://" + conn.LocalAddr().String() + "/large":
+			io.WriteString(conn, strings.Repeat("x", 100))
+		case "gemini://" + conn.LocalAddr().String() + "/exact":
+			io.WriteString(conn, strings.Repeat("x", 10))
+		default:
+			io.WriteString(conn, "partial")
+			time.Sleep(time.Second)
+		}
+	})
+
+	tests := []struct {
+		path     string
+		expected error
+	}{
+		{"/large", ErrBodyTooLarge},
+		{"/exact", nil},
+		{"/idle", ErrBodyTimeout},
+		{"/total", ErrTotalTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			client := NewClient()
+			client.MaxBodySize = 10
+			if tt.path == "/idle" {
+				client.BodyIdleTimeout = 50 * time.Millisecond
+			}
+			if tt.path == "/total" {
+				client.TotalTimeout = 200 * time.Millisecond
+			}
+
+			resp, err := client.Get("gemini://" + addr + tt.path)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+
+			_, err = resp.ReadBody()
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestReadResponseHeaderTooLong(t *testing.T) {
+	header := "20 " + strings.Repeat("x", 5000) + "\r\n"
+	if _, err := ReadResponse(strings.NewReader(header), "gemini://example.com/"); err == nil {
+		t.Error("Expected error for overlong header")
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		if request == "https://example.com/refuse" {
+			io.WriteString(conn, "53 not proxying that\r\n")
+			return
+		}
+		io.WriteString(conn, "20 text/plain\r\n"+request)
+	})
+
+	client := NewClient()
+	if _, err := client.Get("https://example.com/"); err == nil {
+		t.Fatal("Expected error for https URL without a proxy")
+	}
+
+	client.Proxies = map[string]string{"https": addr}
+	resp, err := client.Get("https://example.com/")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	body, err := resp.ReadBody()
+	if err != nil {
+		t.Fatalf("ReadBody failed: %v", err)
+	}
+	if string(body) != "https://example.com/" {
+		t.Errorf("Expected proxy to receive the full URL, got %q", string(body))
+	}
+
+	resp, err = client.Get("https://example.com/refuse")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(resp.Err(), &statusErr) || statusErr.Proxy != addr {
+		t.Fatalf("Expected StatusError from proxy %s, got %v", addr, resp.Err())
+	}
+	if !strings.Contains(statusErr.Error(), "refused") {
+		t.Errorf("Expected refusal to be explained, got %q", statusErr.Error())
+	}
+}
+
+func TestClientUpload(t *testing.T) {
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		expected := "titan://" + conn.LocalAddr().String() + "/page.gmi;size=7;mime=text/gemini;token=s%20cret"
+		if request != expected {
+			io.WriteString(conn, "59 unexpected request "+request+"\r\n")
+			return
+		}
+		body := make([]byte, 7)
+		if _, err := io.ReadFull(conn, body); err != nil || string(body) != "# Hello" {
+			io.WriteString(conn, "59 unexpected body\r\n")
+			return
+		}
+		io.WriteString(conn, "30 gemini://"+conn.LocalAddr().String()+"/page.gmi\r\n")
+	})
+
+	titanURL, err := TitanURL("gemini://" + addr + "/page.gmi?query")
+	if err != nil {
+		t.Fatalf("TitanURL failed: %v", err)
+	}
+
+	resp, err := NewClient().Upload(context.Background(), titanURL, &TitanUpload{
+		Body:  strings.NewReader("# Hello"),
+		Size:  7,
+		Token: "s cret",
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if resp.Status != StatusRedirectTemporary {
+		t.Fatalf("Expected redirect after upload, got %d %s", resp.Status, resp.Meta)
+	}
+	if resp.Meta != "gemini://"+addr+"/page.gmi" {
+		t.Errorf("Unexpected redirect target %q", resp.Meta)
+	}
+}
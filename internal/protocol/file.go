@@ -0,0 +1,139 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// getFile reads a local file:// URL. Gemtext files (.gmi, .gemini) are
+// text/gemini, other files get a MIME type from their extension or, failing
+// that, their content, and directories are returned as generated gemtext
+// listings
+func getFile(u *url.URL) (*Response, error) {
+	rawURL := u.String()
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("file URLs for remote hosts are not supported: %s", u.Host)
+	}
+
+	name := filepath.FromSlash(u.Path)
+	if name == "" {
+		name = string(filepath.Separator)
+	}
+
+	info, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Response{Status: StatusNotFound, Meta: "file not found", URL: rawURL}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		listing, err := directoryListing(name)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{
+			Status: StatusSuccess,
+			Meta:   "text/gemini",
+			URL:    rawURL,
+			Body:   io.NopCloser(strings.NewReader(listing)),
+		}, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType, err := fileMIMEType(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Response{
+		Status: StatusSuccess,
+		Meta:   mimeType,
+		URL:    rawURL,
+		Body:   f,
+	}, nil
+}
+
+// fileMIMEType determines the MIME type of a local file
+func fileMIMEType(f *os.File) (string, error) {
+	ext := strings.ToLower(filepath.Ext(f.Name()))
+	if ext == ".gmi" || ext == ".gemini" {
+		return "text/gemini", nil
+	}
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return mimeType, nil
+	}
+
+	// Sniff the content, then rewind for the caller
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// directoryListing generates a gemtext page linking to the entries of a
+// directory, directories first, and to its parent
+func directoryListing(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir() && !entries[j].IsDir()
+	})
+
+	var b strings.Builder
+	slashDir := filepath.ToSlash(dir)
+	fmt.Fprintf(&b, "# Index of %s\n\n", slashDir)
+
+	// A trailing slash is dropped first so that the parent of /a/b/ is /a
+	if trimmed := strings.TrimSuffix(slashDir, "/"); trimmed != "" {
+		if parent := path.Dir(trimmed); parent != trimmed {
+			fmt.Fprintf(&b, "=> %s ../\n", fileURL(parent, true))
+		}
+	}
+
+	for _, entry := range entries {
+		label := entry.Name()
+		if entry.IsDir() {
+			label += "/"
+		}
+		fmt.Fprintf(&b, "=> %s %s\n", fileURL(path.Join(slashDir, entry.Name()), entry.IsDir()), label)
+	}
+
+	if len(entries) == 0 {
+		b.WriteString("This directory is empty.\n")
+	}
+
+	return b.String(), nil
+}
+
+// fileURL returns the file:// URL for a slash-separated path
+func fileURL(p string, dir bool) string {
+	if dir && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClientFile(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "posts"), 0755)
+	os.WriteFile(filepath.Join(dir, "index.gmi"), []byte("# Home\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes"), []byte("plain notes\n"), 0644)
+
+	fileURL := func(p string) string {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String()
+	}
+
+	client := NewClient()
+
+	tests := []struct {
+		path string
+		mime string
+	}{
+		{filepath.Join(dir, "index.gmi"), "text/gemini"},
+		{filepath.Join(dir, "notes"), "text/plain"},
+		{dir, "text/gemini"},
+	}
+
+	for _, tt := range tests {
+		resp, err := client.Get(fileURL(tt.path))
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", tt.path, err)
+		}
+		if resp.MIMEType() != tt.mime {
+			t.Errorf("Expected MIME type %q for %s, got %q", tt.mime, tt.path, resp.MIMEType())
+		}
+		resp.Close()
+	}
+
+	resp, err := client.Get(fileURL(dir))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	body, err := resp.ReadBody()
+	if err != nil {
+		t.Fatalf("ReadBody failed: %v", err)
+	}
+	listing := string(body)
+
+	for _, want := range []string{
+		"=> " + fileURL(filepath.Dir(dir)) + "/ ../\n",
+		"=> " + fileURL(filepath.Join(dir, "posts")) + "/ posts/\n",
+		"=> " + fileURL(filepath.Join(dir, "index.gmi")) + " index.gmi\n",
+	} {
+		if !strings.Contains(listing, want) {
+			t.Errorf("Expected listing to contain %q, got:\n%s", want, listing)
+		}
+	}
+	if strings.Index(listing, "posts/") > strings.Index(listing, "index.gmi") {
+		t.Error("Expected directories to be listed before files")
+	}
+
+	// A directory URL with a trailing slash links to its real parent
+	resp, err = client.Get(fileURL(filepath.Join(dir, "posts")) + "/")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	body, err = resp.ReadBody()
+	if err != nil {
+		t.Fatalf("ReadBody failed: %v", err)
+	}
+	if want := "=> " + fileURL(dir) + "/ ../\n"; !strings.Contains(string(body), want) {
+		t.Errorf("Expected listing to contain %q, got:\n%s", want, body)
+	}
+
+	listing, err = directoryListing("/")
+	if err != nil {
+		t.Fatalf("directoryListing failed: %v", err)
+	}
+	if strings.Contains(listing, "../") {
+		t.Errorf("Expected no parent link for the root directory, got:\n%s", listing)
+	}
+
+	resp, err = client.Get(fileURL(filepath.Join(dir, "missing.gmi")))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if resp.Status != StatusNotFound {
+		t.Errorf("Expected status 51 for a missing file, got %d", resp.Status)
+	}
+}
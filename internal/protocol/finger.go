@@ -0,0 +1,28 @@
+package protocol
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// FingerDefaultPort is the default Finger port
+const FingerDefaultPort = "79"
+
+// getFinger fetches a finger:// URL. The path (or the user part of the
+// authority, as in finger://user@host) names the user to query; the reply
+// is plain text such as a .plan file
+func (c *Client) getFinger(ctx context.Context, u *url.URL) (*Response, error) {
+	query := strings.TrimPrefix(u.Path, "/")
+	if query == "" && u.User != nil {
+		query = u.User.Username()
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), FingerDefaultPort)
+	}
+
+	return c.fetchTCP(ctx, u.String(), host, query, "text/plain")
+}
@@ -0,0 +1,31 @@
+package protocol
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestClientFinger(t *testing.T) {
+	addr := startTCPServer(t, func(conn net.Conn, query string, _ *bufio.Reader) {
+		io.WriteString(conn, "Plan for "+query+"\r\n")
+	})
+
+	for _, rawURL := range []string{"finger://" + addr + "/alice", "finger://alice@" + addr} {
+		resp, err := NewClient().Get(rawURL)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", rawURL, err)
+		}
+		if resp.MIMEType() != "text/plain" {
+			t.Errorf("Expected text/plain, got %q", resp.MIMEType())
+		}
+		body, err := resp.ReadBody()
+		if err != nil {
+			t.Fatalf("ReadBody failed: %v", err)
+		}
+		if string(body) != "Plan for alice\r\n" {
+			t.Errorf("Expected plan for alice, got %q", string(body))
+		}
+	}
+}
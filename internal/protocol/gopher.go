@@ -0,0 +1,141 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+)
+
+const (
+	// GopherDefaultPort is the default Gopher port
+	GopherDefaultPort = "70"
+
+	// GopherMenuMIME is the MIME type given to gopher menus (item type 1)
+	// so they can be converted to documents like any other body
+	GopherMenuMIME = "application/gopher-menu"
+)
+
+// gopherMIMETypes maps gopher item types to the MIME type reported for them
+var gopherMIMETypes = map[byte]string{
+	'0': "text/plain",
+	'1': GopherMenuMIME,
+	'7': GopherMenuMIME,
+	'h': "text/html",
+	'g': "image/gif",
+	'I': "image/*",
+	'p': "image/png",
+	'd': "application/pdf",
+	's': "audio/*",
+}
+
+// GopherItem splits a gopher URL path into its item type and selector.
+// The path is "/<type><selector>"; an empty path is the root menu
+func GopherItem(u *url.URL) (itemType byte, selector string) {
+	path := strings.TrimPrefix(u.Path, "/")
+	if path == "" {
+		return '1', ""
+	}
+	return path[0], path[1:]
+}
+
+// getGopher fetches a gopher:// URL, presenting the result as a Gemini-style
+// response: menus and search results get GopherMenuMIME, text files
+// text/plain, and a search (type 7) without a query asks for input
+func (c *Client) getGopher(ctx context.Context, u *url.URL) (*Response, error) {
+	rawURL := u.String()
+	itemType, selector := GopherItem(u)
+
+	// Search terms come from the query string or a tab-separated path suffix
+	search := ""
+	if before, after, found := strings.Cut(selector, "\t"); found {
+		selector, search = before, after
+	}
+	if u.RawQuery != "" {
+		query, err := url.PathUnescape(u.RawQuery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid search query: %w", err)
+		}
+		search = query
+	}
+
+	if itemType == '7' && search == "" {
+		return &Response{Status: StatusInput, Meta: "Search", URL: rawURL}, nil
+	}
+
+	mimeType, ok := gopherMIMETypes[itemType]
+	if !ok {
+		mimeType = "application/octet-stream"
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), GopherDefaultPort)
+	}
+
+	request := selector
+	if itemType == '7' {
+		request += "\t" + search
+	}
+
+	resp, err := c.fetchTCP(ctx, rawURL, host, request, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Text and menus end with a line holding a single "."
+	if strings.HasPrefix(mimeType, "text/") || mimeType == GopherMenuMIME {
+		resp.Body = &gopherTextBody{r: bufio.NewReader(resp.Body), closer: resp.Body}
+	}
+
+	return resp, nil
+}
+
+// gopherTextBody reads gopher text or a menu, stopping at the "." line
+// that terminates it and undoing the escaping of lines starting with "."
+type gopherTextBody struct {
+	r      *bufio.Reader
+	closer io.Closer
+	buf    []byte
+	done   bool
+}
+
+// Read returns the body line by line until the terminating "." line
+func (b *gopherTextBody) Read(p []byte) (int, error) {
+	for len(b.buf) == 0 {
+		if b.done {
+			return 0, io.EOF
+		}
+
+		line, err := b.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if err == io.EOF {
+			b.done = true
+		}
+
+		content := bytes.TrimRight(line, "\r\n")
+		if string(content) == "." {
+			b.done = true
+			continue
+		}
+		if bytes.HasPrefix(content, []byte("..")) {
+			line = line[1:]
+		}
+		b.buf = line
+	}
+
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+// Close closes the underlying connection
+func (b *gopherTextBody) Close() error {
+	return b.closer.Close()
+}
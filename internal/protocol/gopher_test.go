@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// startTCPServer starts a plain TCP server that passes the first line of
+// each request to handler, along with a reader for any data that follows,
+// and returns its address
+func startTCPServer(t *testing.T, handler func(conn net.Conn, line string, r *bufio.Reader)) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				handler(conn, strings.TrimRight(line, "\r\n"), r)
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestClientGopher(t *testing.T) {
+	base := "gopher://" + startTCPServer(t, func(conn net.Conn, selector string, _ *bufio.Reader) {
+		switch selector {
+		case "":
+			io.WriteString(conn, "1Docs\t/docs\texample.org\t70\r\n.\r\n")
+		case "/notes.txt":
+			io.WriteString(conn, "first\r\n..dotted\r\n.\r\nafter the end\r\n")
+		case "/search\tgemini protocol":
+			io.WriteString(conn, "0Result\t/r\texample.org\t70\r\n.\r\n")
+		}
+	})
+
+	client := NewClient()
+
+	tests := []struct {
+		path string
+		mime string
+		body string
+	}{
+		{"/", GopherMenuMIME, "1Docs\t/docs\texample.org\t70\r\n"},
+		{"/0/notes.txt", "text/plain", "first\r\n.dotted\r\n"},
+		{"/7/search?gemini%20protocol", GopherMenuMIME, "0Result\t/r\texample.org\t70\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := client.Get(base + tt.path)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if resp.MIMEType() != tt.mime {
+				t.Errorf("Expected MIME type %q, got %q", tt.mime, resp.MIMEType())
+			}
+			body, err := resp.ReadBody()
+			if err != nil {
+				t.Fatalf("ReadBody failed: %v", err)
+			}
+			if string(body) != tt.body {
+				t.Errorf("Expected body %q, got %q", tt.body, string(body))
+			}
+		})
+	}
+
+	resp, err := client.Get(base + "/7/search")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if resp.Status != StatusInput {
+		t.Errorf("Expected search without a query to ask for input, got %d", resp.Status)
+	}
+}
@@ -0,0 +1,207 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// DefaultIdentityLifetime is how long a generated client certificate is valid
+const DefaultIdentityLifetime = 365 * 24 * time.Hour
+
+// KeyType is the public key algorithm of a generated identity
+type KeyType string
+
+const (
+	// KeyEd25519 generates an Ed25519 key
+	KeyEd25519 KeyType = "ed25519"
+
+	// KeyECDSA generates an ECDSA P-256 key, for servers without Ed25519 support
+	KeyECDSA KeyType = "ecdsa"
+)
+
+// IdentityOptions controls how a new identity is generated
+type IdentityOptions struct {
+	// CommonName is the certificate subject common name (defaults to the identity name)
+	CommonName string
+
+	// KeyType is the key algorithm (defaults to Ed25519)
+	KeyType KeyType
+
+	// Lifetime is how long the certificate is valid (defaults to DefaultIdentityLifetime)
+	Lifetime time.Duration
+}
+
+// Identity is a client certificate used to authenticate with capsules
+// that respond with status 60, 61 or 62
+type Identity struct {
+	// Name is the user-visible name of the identity
+	Name string
+
+	// Certificate is the certificate and private key presented during the TLS handshake
+	Certificate tls.Certificate
+}
+
+// Leaf returns the parsed leaf certificate of the identity
+func (id *Identity) Leaf() (*x509.Certificate, error) {
+	if id.Certificate.Leaf != nil {
+		return id.Certificate.Leaf, nil
+	}
+	if len(id.Certificate.Certificate) == 0 {
+		return nil, fmt.Errorf("identity %q has no certificate", id.Name)
+	}
+	return x509.ParseCertificate(id.Certificate.Certificate[0])
+}
+
+// KeyType returns the key algorithm of the identity
+func (id *Identity) KeyType() KeyType {
+	switch id.Certificate.PrivateKey.(type) {
+	case ed25519.PrivateKey:
+		return KeyEd25519
+	case *ecdsa.PrivateKey:
+		return KeyECDSA
+	default:
+		return ""
+	}
+}
+
+// Fingerprint returns the SHA256 fingerprint of the identity's certificate
+func (id *Identity) Fingerprint() string {
+	leaf, err := id.Leaf()
+	if err != nil {
+		return ""
+	}
+	return CertificateFingerprint(leaf)
+}
+
+// GenerateIdentity creates a new self-signed client identity
+func GenerateIdentity(name string, opts IdentityOptions) (*Identity, error) {
+	if err := validateIdentityName(name); err != nil {
+		return nil, err
+	}
+
+	commonName := opts.CommonName
+	if commonName == "" {
+		commonName = name
+	}
+
+	lifetime := opts.Lifetime
+	if lifetime <= 0 {
+		lifetime = DefaultIdentityLifetime
+	}
+
+	var (
+		pub  crypto.PublicKey
+		priv crypto.Signer
+	)
+
+	switch opts.KeyType {
+	case KeyEd25519, "":
+		edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+		pub, priv = edPub, edPriv
+
+	case KeyECDSA:
+		ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+		pub, priv = &ecPriv.PublicKey, ecPriv
+
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", opts.KeyType)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	// Backdate slightly to tolerate clock skew between us and the server
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(lifetime),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated certificate: %w", err)
+	}
+
+	return &Identity{
+		Name: name,
+		Certificate: tls.Certificate{
+			Certificate: [][]byte{der},
+			PrivateKey:  priv,
+			Leaf:        leaf,
+		},
+	}, nil
+}
+
+// EncodePEM returns the certificate and private key of the identity as PEM blocks
+func (id *Identity) EncodePEM() (certPEM, keyPEM []byte, err error) {
+	if len(id.Certificate.Certificate) == 0 {
+		return nil, nil, fmt.Errorf("identity %q has no certificate", id.Name)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(id.Certificate.PrivateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	var certBuf bytes.Buffer
+	for _, der := range id.Certificate.Certificate {
+		if err := pem.Encode(&certBuf, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certBuf.Bytes(), keyPEM, nil
+}
+
+// ParseIdentity builds an identity from PEM-encoded certificate and key data.
+// certPEM and keyPEM may be the same buffer if it holds both blocks
+func ParseIdentity(name string, certPEM, keyPEM []byte) (*Identity, error) {
+	if err := validateIdentityName(name); err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate or key: %w", err)
+	}
+
+	if cert.Leaf == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		cert.Leaf = leaf
+	}
+
+	return &Identity{Name: name, Certificate: cert}, nil
+}
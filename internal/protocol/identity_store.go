@@ -0,0 +1,468 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// identityCertExt and identityKeyExt are the file extensions of stored identities
+	identityCertExt = ".crt"
+	identityKeyExt  = ".key"
+
+	// identityScopesFile is the file holding the URL scopes of stored identities
+	identityScopesFile = "scopes.json"
+
+	// identityScopesVersion is the current scopes file format.
+	// Version 1.0 keys had no scheme and were all Gemini scopes
+	identityScopesVersion = "1.1"
+)
+
+// identityScopes is the on-disk format of the scopes file
+type identityScopes struct {
+	Version string            `json:"version"`
+	Scopes  map[string]string `json:"scopes"`
+}
+
+// IdentityStore holds client identities and the URL scopes they apply to.
+// A scope is a scheme, host and path prefix: an identity used for
+// gemini://example.com/app is also sent for gemini://example.com/app/post,
+// but not for gemini://example.com/apple
+type IdentityStore struct {
+	mu         sync.RWMutex
+	identities map[string]*Identity
+
+	// scopes maps a scope key ("scheme://host/path") to an identity name
+	scopes map[string]string
+
+	// dir is where identities are persisted ("" keeps them in memory only)
+	dir string
+
+	// broken holds the identities in dir that could not be loaded, by name.
+	// Their files and scopes are kept so that they can be repaired by hand
+	broken map[string]error
+}
+
+// NewIdentityStore creates an empty, in-memory identity store
+func NewIdentityStore() *IdentityStore {
+	return &IdentityStore{
+		identities: make(map[string]*Identity),
+		scopes:     make(map[string]string),
+		broken:     make(map[string]error),
+	}
+}
+
+// LoadIdentityStore creates an identity store persisted in dir,
+// loading any identities already stored there.
+// Each identity is kept as <name>.crt and <name>.key PEM files.
+// Identities that cannot be loaded are skipped and reported by LoadErrors
+func LoadIdentityStore(dir string) (*IdentityStore, error) {
+	s := NewIdentityStore()
+	s.dir = dir
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read identities: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != identityCertExt {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), identityCertExt)
+		id, err := loadIdentity(dir, name)
+		if err != nil {
+			s.broken[name] = err
+			continue
+		}
+		s.identities[name] = id
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, identityScopesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read identity scopes: %w", err)
+	}
+
+	var stored identityScopes
+	if err := json.Unmarshal(data, &stored); err != nil {
+		s.broken[identityScopesFile] = fmt.Errorf("failed to parse identity scopes: %w", err)
+		return s, nil
+	}
+	for scope, name := range stored.Scopes {
+		if stored.Version == "1.0" {
+			scope = "gemini://" + scope
+		}
+
+		// Drop scopes whose identity files were removed by hand
+		if _, exists := s.identities[name]; exists || s.broken[name] != nil {
+			s.scopes[scope] = name
+		}
+	}
+
+	return s, nil
+}
+
+// loadIdentity reads the PEM files of the identity called name in dir
+func loadIdentity(dir, name string) (*Identity, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, name+identityCertExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity %q: %w", name, err)
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, name+identityKeyExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity %q: %w", name, err)
+	}
+
+	id, err := ParseIdentity(name, certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load identity %q: %w", name, err)
+	}
+	return id, nil
+}
+
+// LoadErrors returns why stored identities were skipped when the store was
+// loaded, sorted by identity name
+func (s *IdentityStore) LoadErrors() []error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.broken))
+	for name := range s.broken {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := make([]error, len(names))
+	for i, name := range names {
+		errs[i] = s.broken[name]
+	}
+	return errs
+}
+
+// Add adds an identity to the store, failing if the name is already taken
+func (s *IdentityStore) Add(id *Identity) error {
+	if err := validateIdentityName(id.Name); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.identities[id.Name]; exists || s.broken[id.Name] != nil {
+		return fmt.Errorf("identity %q already exists", id.Name)
+	}
+
+	if err := s.writeIdentity(id); err != nil {
+		return err
+	}
+
+	s.identities[id.Name] = id
+	return nil
+}
+
+// Get returns the identity with the given name
+func (s *IdentityStore) Get(name string) (*Identity, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, exists := s.identities[name]
+	return id, exists
+}
+
+// List returns all identities sorted by name
+func (s *IdentityStore) List() []*Identity {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]*Identity, 0, len(s.identities))
+	for _, id := range s.identities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Name < ids[j].Name
+	})
+	return ids
+}
+
+// Rename changes the name of an identity, keeping its scopes
+func (s *IdentityStore) Rename(oldName, newName string) error {
+	if err := validateIdentityName(newName); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, exists := s.identities[oldName]
+	if !exists {
+		return fmt.Errorf("unknown identity: %s", oldName)
+	}
+	if oldName == newName {
+		return nil
+	}
+	if _, exists := s.identities[newName]; exists || s.broken[newName] != nil {
+		return fmt.Errorf("identity %q already exists", newName)
+	}
+
+	if s.dir != "" {
+		var renamed []string
+		for _, ext := range []string{identityCertExt, identityKeyExt} {
+			oldPath := filepath.Join(s.dir, oldName+ext)
+			newPath := filepath.Join(s.dir, newName+ext)
+			if err := os.Rename(oldPath, newPath); err != nil {
+				// Put back the files already renamed so that the
+				// identity is not left split across two names
+				for _, done := range renamed {
+					os.Rename(filepath.Join(s.dir, newName+done), filepath.Join(s.dir, oldName+done))
+				}
+				return fmt.Errorf("failed to rename identity: %w", err)
+			}
+			renamed = append(renamed, ext)
+		}
+	}
+
+	renamed := &Identity{Name: newName, Certificate: id.Certificate}
+	delete(s.identities, oldName)
+	s.identities[newName] = renamed
+
+	for scope, name := range s.scopes {
+		if name == oldName {
+			s.scopes[scope] = newName
+		}
+	}
+
+	return s.saveScopes()
+}
+
+// Delete removes an identity and every scope it is used for
+func (s *IdentityStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.identities[name]; !exists {
+		return fmt.Errorf("unknown identity: %s", name)
+	}
+
+	if s.dir != "" {
+		for _, ext := range []string{identityCertExt, identityKeyExt} {
+			err := os.Remove(filepath.Join(s.dir, name+ext))
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to delete identity: %w", err)
+			}
+		}
+	}
+
+	delete(s.identities, name)
+	for scope, scoped := range s.scopes {
+		if scoped == name {
+			delete(s.scopes, scope)
+		}
+	}
+
+	return s.saveScopes()
+}
+
+// Export returns the certificate and private key of an identity as a single PEM bundle
+func (s *IdentityStore) Export(name string) ([]byte, error) {
+	id, exists := s.Get(name)
+	if !exists {
+		return nil, fmt.Errorf("unknown identity: %s", name)
+	}
+
+	certPEM, keyPEM, err := id.EncodePEM()
+	if err != nil {
+		return nil, err
+	}
+	return append(certPEM, keyPEM...), nil
+}
+
+// Import adds an identity from a PEM bundle holding its certificate and private key
+func (s *IdentityStore) Import(name string, data []byte) (*Identity, error) {
+	id, err := ParseIdentity(name, data, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Add(id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// Scopes returns the scopes ("scheme://host/path") the named identity is used for
+func (s *IdentityStore) Scopes(name string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var scopes []string
+	for scope, scoped := range s.scopes {
+		if scoped == name {
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// Use scopes the named identity to the scheme, host and path of rawURL
+func (s *IdentityStore) Use(name, rawURL string) error {
+	key, err := scopeKey(rawURL)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.identities[name]; !exists {
+		return fmt.Errorf("unknown identity: %s", name)
+	}
+	s.scopes[key] = name
+	return s.saveScopes()
+}
+
+// Forget removes any identity scoped to exactly the scheme, host and path of rawURL
+func (s *IdentityStore) Forget(rawURL string) error {
+	key, err := scopeKey(rawURL)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.scopes, key)
+	return s.saveScopes()
+}
+
+// ForURL returns the identity to present for rawURL, or nil if none applies.
+// When several scopes match, the one with the longest path prefix wins
+func (s *IdentityStore) ForURL(rawURL string) *Identity {
+	key, err := scopeKey(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	best := ""
+	for scope := range s.scopes {
+		if scopeMatches(key, scope) && len(scope) > len(best) {
+			best = scope
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return s.identities[s.scopes[best]]
+}
+
+// scopeMatches reports whether a scope covers a scope key. The scope must
+// be a prefix of the key ending on a path segment boundary, so that
+// /app covers /app and /app/post but not /apple
+func scopeMatches(key, scope string) bool {
+	if !strings.HasPrefix(key, scope) {
+		return false
+	}
+	if len(key) == len(scope) || strings.HasSuffix(scope, "/") {
+		return true
+	}
+	next := key[len(scope)]
+	return next == '/' || next == '?'
+}
+
+// writeIdentity writes an identity's PEM files (caller must hold lock)
+func (s *IdentityStore) writeIdentity(id *Identity) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	certPEM, keyPEM, err := id.EncodePEM()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, id.Name+identityKeyExt), keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to save identity: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, id.Name+identityCertExt), certPEM, 0600); err != nil {
+		return fmt.Errorf("failed to save identity: %w", err)
+	}
+	return nil
+}
+
+// saveScopes writes the scopes file (caller must hold lock)
+func (s *IdentityStore) saveScopes() error {
+	if s.dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(identityScopes{Version: identityScopesVersion, Scopes: s.scopes}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(s.dir, identityScopesFile), data, 0600)
+}
+
+// validateIdentityName checks that a name is usable as a file name
+func validateIdentityName(name string) error {
+	if name == "" {
+		return fmt.Errorf("identity name must not be empty")
+	}
+	if strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid identity name: %q", name)
+	}
+	return nil
+}
+
+// scopeKey normalizes a URL into the "scheme://host/path" form used for
+// scope matching. Titan uploads go to Gemini capsules, so they share the
+// scopes of the capsule's gemini:// URLs
+func scopeKey(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("URL has no host: %s", rawURL)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "titan" {
+		scheme = "gemini"
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != DefaultPort {
+		host = net.JoinHostPort(host, port)
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	return scheme + "://" + host + path, nil
+}
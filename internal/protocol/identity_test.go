@@ -0,0 +1,387 @@
+package protocol
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateIdentity(t *testing.T) {
+	tests := []struct {
+		keyType KeyType
+	}{
+		{KeyEd25519},
+		{KeyECDSA},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.keyType), func(t *testing.T) {
+			id, err := GenerateIdentity("alice", IdentityOptions{
+				CommonName: "Alice",
+				KeyType:    tt.keyType,
+				Lifetime:   48 * time.Hour,
+			})
+			if err != nil {
+				t.Fatalf("GenerateIdentity failed: %v", err)
+			}
+
+			if id.KeyType() != tt.keyType {
+				t.Errorf("Expected key type %s, got %s", tt.keyType, id.KeyType())
+			}
+
+			leaf, err := id.Leaf()
+			if err != nil {
+				t.Fatalf("Leaf failed: %v", err)
+			}
+			if leaf.Subject.CommonName != "Alice" {
+				t.Errorf("Expected common name %q, got %q", "Alice", leaf.Subject.CommonName)
+			}
+			if leaf.NotAfter.After(time.Now().Add(49 * time.Hour)) {
+				t.Errorf("Expected lifetime of 48h, expires %v", leaf.NotAfter)
+			}
+		})
+	}
+}
+
+func TestGenerateIdentityInvalid(t *testing.T) {
+	if _, err := GenerateIdentity("", IdentityOptions{}); err == nil {
+		t.Error("Expected error for empty name")
+	}
+	if _, err := GenerateIdentity("../evil", IdentityOptions{}); err == nil {
+		t.Error("Expected error for name with path separator")
+	}
+	if _, err := GenerateIdentity("bob", IdentityOptions{KeyType: "rsa"}); err == nil {
+		t.Error("Expected error for unsupported key type")
+	}
+}
+
+func TestIdentityScopes(t *testing.T) {
+	s := NewIdentityStore()
+	for _, name := range []string{"site", "app"} {
+		id, err := GenerateIdentity(name, IdentityOptions{})
+		if err != nil {
+			t.Fatalf("GenerateIdentity failed: %v", err)
+		}
+		if err := s.Add(id); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	if err := s.Use("site", "gemini://example.com/"); err != nil {
+		t.Fatalf("Use failed: %v", err)
+	}
+	if err := s.Use("app", "gemini://Example.com:1965/app?login"); err != nil {
+		t.Fatalf("Use failed: %v", err)
+	}
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"gemini://example.com/", "site"},
+		{"gemini://example.com/other", "site"},
+		{"gemini://example.com/app", "app"},
+		{"gemini://example.com/app/post?hello", "app"},
+		{"gemini://example.com:1966/app", ""},
+		{"gemini://other.example.com/", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got := ""
+			if id := s.ForURL(tt.url); id != nil {
+				got = id.Name
+			}
+			if got != tt.expected {
+				t.Errorf("Expected identity %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if err := s.Use("missing", "gemini://example.com/"); err == nil {
+		t.Error("Expected error when using unknown identity")
+	}
+}
+
+func TestScopeKey(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"gemini://example.com", "gemini://example.com/"},
+		{"gemini://Example.COM/App", "gemini://example.com/App"},
+		{"gemini://example.com:1965/app?query#frag", "gemini://example.com/app"},
+		{"gemini://example.com:1966/app", "gemini://example.com:1966/app"},
+		{"titan://example.com/app;size=10", "gemini://example.com/app;size=10"},
+		{"spartan://example.com/app", "spartan://example.com/app"},
+		{"gemini://example.com/a%20b", "gemini://example.com/a%20b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, err := scopeKey(tt.url)
+			if err != nil {
+				t.Fatalf("scopeKey failed: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := scopeKey("about:identities"); err == nil {
+		t.Error("Expected error for URL without host")
+	}
+}
+
+func TestIdentityScopeBoundaries(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    string
+		url      string
+		expected bool
+	}{
+		{"exact", "gemini://example.com/app", "gemini://example.com/app", true},
+		{"query", "gemini://example.com/app", "gemini://example.com/app?post", true},
+		{"subpath", "gemini://example.com/app", "gemini://example.com/app/post", true},
+		{"sibling", "gemini://example.com/app", "gemini://example.com/apple", false},
+		{"sibling with dash", "gemini://example.com/app", "gemini://example.com/app-admin/", false},
+		{"directory scope", "gemini://example.com/app/", "gemini://example.com/app/post", true},
+		{"directory scope without slash", "gemini://example.com/app/", "gemini://example.com/app", false},
+		{"root", "gemini://example.com/", "gemini://example.com/anything", true},
+		{"host prefix", "gemini://example.com/", "gemini://example.com.evil/", false},
+		{"port", "gemini://example.com/", "gemini://example.com:1966/", false},
+		{"other scheme", "gemini://example.com/", "spartan://example.com/", false},
+		{"titan", "gemini://example.com/app", "titan://example.com/app/upload;size=1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewIdentityStore()
+			id, err := GenerateIdentity("test", IdentityOptions{})
+			if err != nil {
+				t.Fatalf("GenerateIdentity failed: %v", err)
+			}
+			if err := s.Add(id); err != nil {
+				t.Fatalf("Add failed: %v", err)
+			}
+			if err := s.Use("test", tt.scope); err != nil {
+				t.Fatalf("Use failed: %v", err)
+			}
+
+			if got := s.ForURL(tt.url) != nil; got != tt.expected {
+				t.Errorf("ForURL(%q) with scope %q: expected match %v, got %v", tt.url, tt.scope, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIdentityScopesMigration(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := LoadIdentityStore(dir)
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+	id, err := GenerateIdentity("alice", IdentityOptions{})
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	if err := s.Add(id); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// Version 1.0 scopes had no scheme
+	old := `{"version": "1.0", "scopes": {"example.com/forum": "alice"}}`
+	if err := os.WriteFile(filepath.Join(dir, identityScopesFile), []byte(old), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadIdentityStore(dir)
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+	if got := loaded.ForURL("gemini://example.com/forum/thread"); got == nil || got.Name != "alice" {
+		t.Errorf("Expected old scope to apply to gemini URLs, got %v", got)
+	}
+	if scopes := loaded.Scopes("alice"); len(scopes) != 1 || scopes[0] != "gemini://example.com/forum" {
+		t.Errorf("Expected migrated scope, got %v", scopes)
+	}
+}
+
+func TestIdentityStorePersistence(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := LoadIdentityStore(dir)
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+
+	id, err := GenerateIdentity("alice", IdentityOptions{KeyType: KeyECDSA})
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	if err := s.Add(id); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := s.Use("alice", "gemini://example.com/forum"); err != nil {
+		t.Fatalf("Use failed: %v", err)
+	}
+	if err := s.Rename("alice", "bob"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	loaded, err := LoadIdentityStore(dir)
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+	bob, exists := loaded.Get("bob")
+	if !exists {
+		t.Fatal("Expected renamed identity to be loaded")
+	}
+	if bob.Fingerprint() != id.Fingerprint() {
+		t.Error("Loaded identity has a different certificate")
+	}
+	if got := loaded.ForURL("gemini://example.com/forum/thread"); got == nil || got.Name != "bob" {
+		t.Errorf("Expected scope to follow rename, got %v", got)
+	}
+
+	if err := loaded.Delete("bob"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	reloaded, err := LoadIdentityStore(dir)
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+	if len(reloaded.List()) != 0 {
+		t.Errorf("Expected no identities after delete, got %d", len(reloaded.List()))
+	}
+}
+
+func TestIdentityRenameRollback(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := LoadIdentityStore(dir)
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+	id, err := GenerateIdentity("alice", IdentityOptions{})
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	if err := s.Add(id); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// A non-empty directory where the renamed key would go makes the
+	// second rename fail after the certificate has been renamed
+	if err := os.MkdirAll(filepath.Join(dir, "bob"+identityKeyExt, "blocker"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Rename("alice", "bob"); err == nil {
+		t.Fatal("Expected rename to fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "alice"+identityCertExt)); err != nil {
+		t.Errorf("Expected certificate to be restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bob"+identityCertExt)); !os.IsNotExist(err) {
+		t.Errorf("Expected no certificate under the new name, got %v", err)
+	}
+	if _, exists := s.Get("alice"); !exists {
+		t.Error("Expected identity to keep its old name")
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, "bob"+identityKeyExt)); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadIdentityStore(dir)
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+	if _, exists := loaded.Get("alice"); !exists {
+		t.Error("Expected identity to load under its old name")
+	}
+}
+
+func TestIdentityExportImport(t *testing.T) {
+	s := NewIdentityStore()
+	id, err := GenerateIdentity("alice", IdentityOptions{})
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	if err := s.Add(id); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	data, err := s.Export("alice")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	imported, err := s.Import("alice-copy", data)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if imported.Fingerprint() != id.Fingerprint() {
+		t.Error("Imported identity has a different certificate")
+	}
+	if imported.KeyType() != KeyEd25519 {
+		t.Errorf("Expected key type %s, got %s", KeyEd25519, imported.KeyType())
+	}
+
+	if _, err := s.Import("alice", data); err == nil {
+		t.Error("Expected error importing over an existing name")
+	}
+}
+
+func TestIdentityStoreSkipsBrokenEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := LoadIdentityStore(dir)
+	if err != nil {
+		t.Fatalf("LoadIdentityStore failed: %v", err)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		id, err := GenerateIdentity(name, IdentityOptions{})
+		if err != nil {
+			t.Fatalf("GenerateIdentity failed: %v", err)
+		}
+		if err := s.Add(id); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := s.Use(name, "gemini://example.com/"+name); err != nil {
+			t.Fatalf("Use failed: %v", err)
+		}
+	}
+
+	// A certificate without its key can't be loaded
+	if err := os.Remove(filepath.Join(dir, "bob"+identityKeyExt)); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadIdentityStore(dir)
+	if err != nil {
+		t.Fatalf("Expected the store to load despite a broken identity: %v", err)
+	}
+	if _, exists := loaded.Get("alice"); !exists {
+		t.Error("Expected the intact identity to be loaded")
+	}
+	if errs := loaded.LoadErrors(); len(errs) != 1 || !strings.Contains(errs[0].Error(), "bob") {
+		t.Errorf("Expected one load error for bob, got %v", errs)
+	}
+
+	// The broken identity's name and scope are kept for when it is repaired
+	id, _ := GenerateIdentity("bob", IdentityOptions{})
+	if err := loaded.Add(id); err == nil {
+		t.Error("Expected adding over a broken identity to fail")
+	}
+	if err := loaded.Use("alice", "gemini://example.com/other"); err != nil {
+		t.Fatalf("Use failed: %v", err)
+	}
+	if scopes := loaded.Scopes("bob"); len(scopes) != 1 {
+		t.Errorf("Expected the broken identity's scope to be kept, got %v", scopes)
+	}
+}
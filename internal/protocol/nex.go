@@ -0,0 +1,46 @@
+package protocol
+
+import (
+	"context"
+	"mime"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// NexDefaultPort is the default Nex port
+const NexDefaultPort = "1900"
+
+// getNex fetches a nex:// URL. The request is just the path; directories
+// (paths ending in "/") are listings made of "=>" link lines, so they are
+// reported as text/gemini, and files get a MIME type from their extension
+func (c *Client) getNex(ctx context.Context, u *url.URL) (*Response, error) {
+	selector := u.Path
+	if selector == "" {
+		selector = "/"
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), NexDefaultPort)
+	}
+
+	return c.fetchTCP(ctx, u.String(), host, selector, nexMIMEType(selector))
+}
+
+// nexMIMEType guesses the MIME type of a Nex document from its path
+func nexMIMEType(selector string) string {
+	ext := strings.ToLower(path.Ext(selector))
+	switch {
+	case strings.HasSuffix(selector, "/"), ext == ".gmi", ext == ".gemini":
+		return "text/gemini"
+	case ext == "":
+		return "text/plain"
+	}
+
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,45 @@
+package protocol
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestClientNex(t *testing.T) {
+	addr := startTCPServer(t, func(conn net.Conn, selector string, _ *bufio.Reader) {
+		switch selector {
+		case "/":
+			io.WriteString(conn, "=> notes/\n=> about.txt\n")
+		case "/about.txt":
+			io.WriteString(conn, "About this site\n")
+		}
+	})
+
+	tests := []struct {
+		path string
+		mime string
+		body string
+	}{
+		{"", "text/gemini", "=> notes/\n=> about.txt\n"},
+		{"/about.txt", "text/plain", "About this site\n"},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewClient().Get("nex://" + addr + tt.path)
+		if err != nil {
+			t.Fatalf("Get %q failed: %v", tt.path, err)
+		}
+		if resp.MIMEType() != tt.mime {
+			t.Errorf("Expected MIME type %q for %q, got %q", tt.mime, tt.path, resp.MIMEType())
+		}
+		body, err := resp.ReadBody()
+		if err != nil {
+			t.Fatalf("ReadBody failed: %v", err)
+		}
+		if string(body) != tt.body {
+			t.Errorf("Expected body %q, got %q", tt.body, string(body))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package protocol
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Redirect is one redirect followed while making a request
+type Redirect struct {
+	// URL is the URL that answered with the redirect
+	URL string
+
+	// Target is the URL it redirected to, resolved against URL
+	Target string
+
+	// Permanent is true for a permanent (31) redirect
+	Permanent bool
+}
+
+// schemePorts are the default ports of the schemes the client fetches
+var schemePorts = map[string]string{
+	"gemini":  DefaultPort,
+	"titan":   DefaultPort,
+	"gopher":  GopherDefaultPort,
+	"spartan": SpartanDefaultPort,
+	"finger":  FingerDefaultPort,
+	"nex":     NexDefaultPort,
+}
+
+// SchemePort returns the default port of a scheme the client fetches, or
+// "" for other schemes
+func SchemePort(scheme string) string {
+	return schemePorts[strings.ToLower(scheme)]
+}
+
+// OriginHost returns the lowercased host name and port a URL is served
+// from, with its scheme's default port filled in. Certificates and
+// back-off windows are kept under this key
+func OriginHost(u *url.URL) string {
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), originPort(u))
+}
+
+// sameOrigin reports whether two URLs have the same scheme, host and port
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(ua.Scheme, ub.Scheme) &&
+		strings.EqualFold(ua.Hostname(), ub.Hostname()) &&
+		originPort(ua) == originPort(ub)
+}
+
+// originPort returns the port of a URL, or its scheme's default port
+func originPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	return SchemePort(u.Scheme)
+}
+
+// redirectLoops reports whether following next would revisit a URL
+// already in the chain
+func redirectLoops(chain []Redirect, next Redirect) bool {
+	if next.Target == next.URL {
+		return true
+	}
+	for _, r := range chain {
+		if r.URL == next.Target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package protocol
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestClientRedirectChain(t *testing.T) {
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		switch {
+		case strings.HasSuffix(request, "/a"):
+			io.WriteString(conn, "31 /b\r\n")
+		case strings.HasSuffix(request, "/b"):
+			io.WriteString(conn, "30 c\r\n")
+		default:
+			io.WriteString(conn, "20 text/gemini\r\n"+request+"\r\n")
+		}
+	})
+	base := "gemini://" + addr
+
+	resp, err := NewClient().Get(base + "/a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.URL != base+"/c" {
+		t.Errorf("Expected final URL %s, got %s", base+"/c", resp.URL)
+	}
+
+	expected := []Redirect{
+		{URL: base + "/a", Target: base + "/b", Permanent: true},
+		{URL: base + "/b", Target: base + "/c", Permanent: false},
+	}
+	if len(resp.Redirects) != len(expected) {
+		t.Fatalf("Expected %d redirects, got %v", len(expected), resp.Redirects)
+	}
+	for i, r := range resp.Redirects {
+		if r != expected[i] {
+			t.Errorf("Redirect %d: expected %+v, got %+v", i, expected[i], r)
+		}
+	}
+}
+
+func TestClientRedirectLoop(t *testing.T) {
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		if strings.HasSuffix(request, "/a") {
+			io.WriteString(conn, "30 /b\r\n")
+		} else {
+			io.WriteString(conn, "30 /a\r\n")
+		}
+	})
+
+	_, err := NewClient().Get("gemini://" + addr + "/a")
+	if err == nil || !strings.Contains(err.Error(), "redirect loop") {
+		t.Errorf("Expected redirect loop error, got %v", err)
+	}
+}
+
+func TestClientRedirectApproval(t *testing.T) {
+	var addr string
+	addr = startTestServer(t, func(conn net.Conn, request string) {
+		if strings.HasPrefix(request, "gemini://127.0.0.1") {
+			// Same server, different host name
+			_, port, _ := net.SplitHostPort(addr)
+			io.WriteString(conn, "31 gemini://localhost:"+port+"/moved\r\n")
+			return
+		}
+		io.WriteString(conn, "20 text/gemini\r\n# Moved\r\n")
+	})
+	_, port, _ := net.SplitHostPort(addr)
+	target := "gemini://localhost:" + port + "/moved"
+
+	var asked []string
+	client := NewClient()
+	client.OnRedirect = func(from, to string, permanent bool) bool {
+		asked = append(asked, to)
+		if !permanent {
+			t.Errorf("Expected a permanent redirect")
+		}
+		return false
+	}
+
+	resp, err := client.Get("gemini://" + addr + "/page")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if resp.Status != StatusRedirectPermanent || resp.Meta != target {
+		t.Errorf("Expected unfollowed redirect to %s, got %d %s", target, resp.Status, resp.Meta)
+	}
+	if len(asked) != 1 || asked[0] != target {
+		t.Errorf("Expected approval to be asked for %s, got %v", target, asked)
+	}
+
+	client.OnRedirect = func(from, to string, permanent bool) bool { return true }
+	resp, err = client.Get("gemini://" + addr + "/page")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Status != StatusSuccess || resp.URL != target {
+		t.Errorf("Expected success at %s, got %d at %s", target, resp.Status, resp.URL)
+	}
+}
+
+func TestClientRedirectRefused(t *testing.T) {
+	targets := map[string]string{
+		"/file":  "file:///etc/passwd",
+		"/about": "about:certs/clear?yes",
+		"/other": "https://example.com/",
+	}
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		u, _ := url.Parse(request)
+		io.WriteString(conn, "31 "+targets[u.Path]+"\r\n")
+	})
+
+	for path, target := range targets {
+		t.Run(path, func(t *testing.T) {
+			client := NewClient()
+			client.OnRedirect = func(from, to string, permanent bool) bool { return true }
+			if _, err := client.Get("gemini://" + addr + path); err == nil {
+				t.Errorf("Expected redirect to %s to be refused", target)
+			}
+
+			client.OnRedirect = nil
+			if _, err := client.Get("gemini://" + addr + path); err == nil {
+				t.Errorf("Expected redirect to %s to be refused without OnRedirect", target)
+			}
+		})
+	}
+
+	// A proxied scheme can be fetched, so it may be redirected to
+	client := NewClient()
+	client.Proxies = map[string]string{"https": addr}
+	client.OnRedirect = func(from, to string, permanent bool) bool { return false }
+	resp, err := client.Get("gemini://" + addr + "/other")
+	if err != nil {
+		t.Fatalf("Expected redirect to a proxied scheme to be allowed: %v", err)
+	}
+	if resp.Status != StatusRedirectPermanent || resp.Meta != targets["/other"] {
+		t.Errorf("Expected unfollowed redirect to %s, got %d %s", targets["/other"], resp.Status, resp.Meta)
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"gemini://example.org/a", "gemini://example.org/b", true},
+		{"gemini://example.org/", "gemini://EXAMPLE.org:1965/", true},
+		{"gemini://example.org/", "gemini://example.org:1966/", false},
+		{"gemini://example.org/", "gemini://example.com/", false},
+		{"gemini://example.org/", "gopher://example.org/", false},
+		{"spartan://example.org/", "spartan://example.org:300/x", true},
+	}
+
+	for _, tt := range tests {
+		if got := sameOrigin(tt.a, tt.b); got != tt.same {
+			t.Errorf("sameOrigin(%q, %q) = %v, expected %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
+
+func TestOriginHost(t *testing.T) {
+	tests := []struct {
+		url  string
+		host string
+	}{
+		{"gemini://EXAMPLE.org/", "example.org:1965"},
+		{"titan://example.org/a;size=1", "example.org:1965"},
+		{"gemini://example.org:1966/", "example.org:1966"},
+		{"gopher://example.org/1/", "example.org:70"},
+		{"gemini://[::1]/", "[::1]:1965"},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatalf("url.Parse(%q) failed: %v", tt.url, err)
+		}
+		if got := OriginHost(u); got != tt.host {
+			t.Errorf("OriginHost(%q) = %q, expected %q", tt.url, got, tt.host)
+		}
+	}
+}
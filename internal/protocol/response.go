@@ -2,8 +2,10 @@ package protocol
 
 import (
 	"bufio"
+	"crypto/tls"
 	"fmt"
 	"io"
+	"mime"
 	"strconv"
 	"strings"
 )
@@ -19,13 +21,25 @@ type Response struct {
 	// Body contains the response body (nil for non-success responses)
 	Body io.ReadCloser
 
-	// TLSState contains information about the TLS connection
-	// TLSState *tls.ConnectionState
+	// TLSState describes the TLS connection the response arrived on,
+	// including the server certificate (nil for plain TCP and local responses)
+	TLSState *tls.ConnectionState
 
-	// URL is the URL that was requested
+	// URL is the URL that was requested. When redirects were followed it is
+	// the final URL, and Redirects lists the steps taken to reach it
 	URL string
+
+	// Redirects are the redirects followed to reach this response, in order
+	Redirects []Redirect
+
+	// Proxy is the proxy the request was sent through ("" if sent directly)
+	Proxy string
 }
 
+// maxHeaderLength is the longest valid response header:
+// a two digit status, a space, 1024 bytes of meta and CR LF
+const maxHeaderLength = 2 + 1 + 1024 + 2
+
 // ParseResponseHeader parses the response header line
 // Format: <STATUS><SPACE><META><CR><LF>
 func ParseResponseHeader(line string) (StatusCode, string, error) {
@@ -86,10 +100,16 @@ func ReadResponse(r io.Reader, url string) (*Response, error) {
 	bufReader := bufio.NewReader(r)
 
 	// Read the header line (terminated by CR LF)
-	headerLine, err := bufReader.ReadString('\n')
+	// ReadSlice is bounded by the buffer size, so a server cannot make us
+	// buffer an arbitrarily long header
+	headerBytes, err := bufReader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || len(headerBytes) > maxHeaderLength {
+		return nil, fmt.Errorf("response header too long (max %d bytes)", maxHeaderLength)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response header: %w", err)
 	}
+	headerLine := string(headerBytes)
 
 	// Parse the header
 	status, meta, err := ParseResponseHeader(headerLine)
@@ -133,12 +153,65 @@ func (r *Response) MIMEType() string {
 	return strings.TrimSpace(parts[0])
 }
 
+// Params returns the parameters of the MIME type in the meta field of a
+// success response (such as charset and lang), with lowercased names
+func (r *Response) Params() map[string]string {
+	if !r.Status.IsSuccess() {
+		return nil
+	}
+
+	_, params, err := mime.ParseMediaType(r.Meta)
+	if err != nil {
+		return nil
+	}
+	return params
+}
+
+// Charset returns the charset of a text response, which is UTF-8 unless
+// the meta field says otherwise ("" for other types)
+func (r *Response) Charset() string {
+	if !strings.HasPrefix(r.MIMEType(), "text/") {
+		return ""
+	}
+	if charset := r.Params()["charset"]; charset != "" {
+		return strings.ToLower(charset)
+	}
+	return DefaultCharset
+}
+
+// Lang returns the language of the response content, from the lang
+// parameter of its MIME type ("" if not given)
+func (r *Response) Lang() string {
+	return r.Params()["lang"]
+}
+
+// TextBody returns the body decoded from the response's charset to UTF-8
+func (r *Response) TextBody() (io.Reader, error) {
+	if r.Body == nil {
+		return nil, fmt.Errorf("no response body")
+	}
+	return NewTextReader(r.Body, r.Charset())
+}
+
 // IsGemtext returns true if the response is a gemtext document
 func (r *Response) IsGemtext() bool {
 	mimeType := r.MIMEType()
 	return mimeType == "text/gemini" || mimeType == ""
 }
 
+// Err returns a *StatusError describing a non-success response, or nil
+func (r *Response) Err() error {
+	if r.Status.IsSuccess() {
+		return nil
+	}
+	return &StatusError{
+		Status: r.Status,
+		Meta:   r.Meta,
+		URL:    r.URL,
+		Proxy:  r.Proxy,
+	}
+}
+
 // ReadBody reads the entire response body
 func (r *Response) ReadBody() ([]byte, error) {
 	if r.Body == nil {
@@ -0,0 +1,149 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSlowDownWait is how long to wait after a 44 response whose
+	// meta is not a number of seconds
+	DefaultSlowDownWait = 10 * time.Second
+
+	// maxSlowDownRetries limits automatic retries of a single request
+	maxSlowDownRetries = 3
+)
+
+// ErrSlowDown is matched, with errors.Is, by the *SlowDownError returned
+// for requests to a host that is still in its 44 SLOW DOWN window
+var ErrSlowDown = errors.New("server asked to slow down")
+
+// SlowDownError reports a request that was not sent because its host
+// answered an earlier request with 44 SLOW DOWN
+type SlowDownError struct {
+	// Host is the host (host:port) that asked to slow down
+	Host string
+
+	// Until is when requests to the host are allowed again
+	Until time.Time
+}
+
+// Error describes how long is left to wait
+func (e *SlowDownError) Error() string {
+	wait := time.Until(e.Until).Round(time.Second)
+	return fmt.Sprintf("%s asked to slow down: not sending requests for another %s", e.Host, wait)
+}
+
+// Is makes errors.Is(err, ErrSlowDown) true for a *SlowDownError
+func (e *SlowDownError) Is(target error) bool {
+	return target == ErrSlowDown
+}
+
+// ParseSlowDown returns the wait requested by the meta of a 44 response,
+// which is a number of seconds
+func ParseSlowDown(meta string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(meta))
+	if err != nil || seconds < 0 {
+		return DefaultSlowDownWait
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Backoff tracks hosts that answered 44 SLOW DOWN, so that no more
+// requests are sent to them until their wait has passed
+type Backoff struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// NewBackoff creates an empty back-off tracker
+func NewBackoff() *Backoff {
+	return &Backoff{until: make(map[string]time.Time)}
+}
+
+// SlowDown records that host asked for no requests during wait
+func (b *Backoff) SlowDown(host string, wait time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	until := time.Now().Add(wait)
+	if until.After(b.until[host]) {
+		b.until[host] = until
+	}
+}
+
+// Until returns when requests to host are allowed again (zero if they are now)
+func (b *Backoff) Until(host string) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	until, ok := b.until[host]
+	if ok && !time.Now().Before(until) {
+		delete(b.until, host)
+		return time.Time{}
+	}
+	return until
+}
+
+// Check returns a *SlowDownError if host is still in its back-off window
+func (b *Backoff) Check(host string) error {
+	if until := b.Until(host); !until.IsZero() {
+		return &SlowDownError{Host: host, Until: until}
+	}
+	return nil
+}
+
+// BackoffHost returns the key a request for rawURL is tracked under by the
+// client's Backoff: the origin's host and port, or the proxy's for
+// requests sent through a proxy, since it is the proxy that answers
+func (c *Client) BackoffHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	if proxy := c.Proxies[u.Scheme]; proxy != "" && u.Scheme != "gemini" {
+		return KnownHostKey(proxy)
+	}
+	return OriginHost(u)
+}
+
+// fetchPolitely performs a single request, unless its host is in a back-off
+// window. A 44 response starts a window and, if the wait is no longer than
+// RetrySlowDown, the request is retried once it has passed
+func (c *Client) fetchPolitely(ctx context.Context, rawURL string) (*Response, error) {
+	if c.Backoff == nil {
+		return c.fetch(ctx, rawURL)
+	}
+
+	host := c.BackoffHost(rawURL)
+	for retries := 0; ; retries++ {
+		if err := c.Backoff.Check(host); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.fetch(ctx, rawURL)
+		if err != nil || resp.Status != StatusSlowDown {
+			return resp, err
+		}
+
+		wait := ParseSlowDown(resp.Meta)
+		c.Backoff.SlowDown(host, wait)
+		if wait > c.RetrySlowDown || retries >= maxSlowDownRetries {
+			return resp, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, contextError(ctx, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
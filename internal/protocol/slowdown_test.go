@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseSlowDown(t *testing.T) {
+	tests := []struct {
+		meta string
+		wait time.Duration
+	}{
+		{"30", 30 * time.Second},
+		{" 5 ", 5 * time.Second},
+		{"0", 0},
+		{"", DefaultSlowDownWait},
+		{"soon", DefaultSlowDownWait},
+		{"-1", DefaultSlowDownWait},
+	}
+
+	for _, tt := range tests {
+		if got := ParseSlowDown(tt.meta); got != tt.wait {
+			t.Errorf("ParseSlowDown(%q) = %v, expected %v", tt.meta, got, tt.wait)
+		}
+	}
+}
+
+func TestClientSlowDownBackoff(t *testing.T) {
+	var requests atomic.Int32
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		requests.Add(1)
+		io.WriteString(conn, "44 60\r\n")
+	})
+	pageURL := "gemini://" + addr + "/"
+
+	client := NewClient()
+	resp, err := client.Get(pageURL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if resp.Status != StatusSlowDown {
+		t.Fatalf("Expected status 44, got %d", resp.Status)
+	}
+
+	// The host is not contacted again until the wait has passed
+	_, err = client.Get(pageURL)
+	var slow *SlowDownError
+	if !errors.As(err, &slow) || !errors.Is(err, ErrSlowDown) {
+		t.Fatalf("Expected SlowDownError, got %v", err)
+	}
+	if wait := time.Until(slow.Until); wait < 55*time.Second || wait > 60*time.Second {
+		t.Errorf("Expected about 60s left to wait, got %v", wait)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected 1 request to reach the server, got %d", n)
+	}
+}
+
+func TestClientSlowDownRetry(t *testing.T) {
+	var requests atomic.Int32
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		if requests.Add(1) == 1 {
+			io.WriteString(conn, "44 0\r\n")
+			return
+		}
+		io.WriteString(conn, "20 text/gemini\r\n# Hello\r\n")
+	})
+
+	client := NewClient()
+	client.RetrySlowDown = time.Second
+
+	resp, err := client.Get("gemini://" + addr + "/")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Status != StatusSuccess {
+		t.Errorf("Expected success after retrying, got %d", resp.Status)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+}
+
+func TestClientSlowDownProxied(t *testing.T) {
+	var requests atomic.Int32
+	addr := startTestServer(t, func(conn net.Conn, request string) {
+		requests.Add(1)
+		io.WriteString(conn, "44 60\r\n")
+	})
+
+	client := NewClient()
+	client.Proxies = map[string]string{"https": addr}
+	if _, err := client.Get("https://example.org/"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// The proxy sent the 44, so every site behind it waits
+	if host := client.BackoffHost("https://example.org/"); host != KnownHostKey(addr) {
+		t.Errorf("Expected back-off on the proxy %s, got %s", KnownHostKey(addr), host)
+	}
+	_, err := client.Get("https://example.net/")
+	if !errors.Is(err, ErrSlowDown) {
+		t.Fatalf("Expected ErrSlowDown, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected 1 request to reach the proxy, got %d", n)
+	}
+}
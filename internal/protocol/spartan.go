@@ -0,0 +1,125 @@
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpartanDefaultPort is the default Spartan port
+const SpartanDefaultPort = "300"
+
+// getSpartan fetches a spartan:// URL. Spartan is plain TCP; the request
+// line is "<host> <path> <content-length>" followed by that much data.
+// Data for prompt links (=:) is carried in the URL's query, which is sent
+// as the request body rather than as part of the path. The single-digit
+// Spartan statuses are mapped to their Gemini equivalents
+func (c *Client) getSpartan(ctx context.Context, u *url.URL) (*Response, error) {
+	rawURL := u.String()
+
+	data := ""
+	if u.RawQuery != "" {
+		query, err := url.PathUnescape(u.RawQuery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+		data = query
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), SpartanDefaultPort)
+	}
+
+	conn, closeConn, err := c.dialTCP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.HeaderTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.HeaderTimeout))
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	request := fmt.Sprintf("%s %s %d\r\n%s", u.Hostname(), path, len(data), data)
+	if _, err := io.WriteString(conn, request); err != nil {
+		closeConn()
+		return nil, fmt.Errorf("failed to send request: %w", timeoutError(ctx, err, ErrHeaderTimeout))
+	}
+
+	resp, err := readSpartanResponse(conn, rawURL)
+	if err != nil {
+		closeConn()
+		return nil, fmt.Errorf("failed to read response: %w", timeoutError(ctx, err, ErrHeaderTimeout))
+	}
+	conn.SetDeadline(time.Time{})
+
+	// Redirects are to a path on the same host
+	if resp.Status.IsRedirect() {
+		target := *u
+		target.RawQuery = ""
+		target.Path = resp.Meta
+		target.RawPath = ""
+		resp.Meta = target.String()
+	}
+
+	if !resp.Status.IsSuccess() {
+		closeConn()
+		return resp, nil
+	}
+
+	c.attachBody(ctx, resp, conn, closeConn)
+	return resp, nil
+}
+
+// spartanStatuses maps Spartan status digits to Gemini status codes
+var spartanStatuses = map[int]StatusCode{
+	2: StatusSuccess,
+	3: StatusRedirectTemporary,
+	4: StatusBadRequest,
+	5: StatusTemporaryFailure,
+}
+
+// readSpartanResponse reads a Spartan response header ("<digit> <meta>")
+func readSpartanResponse(r io.Reader, rawURL string) (*Response, error) {
+	bufReader := bufio.NewReader(r)
+
+	headerBytes, err := bufReader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || len(headerBytes) > maxHeaderLength {
+		return nil, fmt.Errorf("response header too long (max %d bytes)", maxHeaderLength)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response header: %w", err)
+	}
+
+	header := strings.TrimRight(string(headerBytes), "\r\n")
+	code, meta, _ := strings.Cut(header, " ")
+	digit, err := strconv.Atoi(code)
+	if err != nil || len(code) != 1 {
+		return nil, fmt.Errorf("invalid status code: %q", code)
+	}
+	status, ok := spartanStatuses[digit]
+	if !ok {
+		return nil, fmt.Errorf("unknown status code: %d", digit)
+	}
+
+	resp := &Response{
+		Status: status,
+		Meta:   meta,
+		URL:    rawURL,
+	}
+	if status.IsSuccess() {
+		resp.Body = io.NopCloser(bufReader)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,75 @@
+package protocol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestClientSpartan(t *testing.T) {
+	addr := startTCPServer(t, func(conn net.Conn, request string, r *bufio.Reader) {
+		fields := strings.Fields(request)
+		if len(fields) != 3 {
+			io.WriteString(conn, "4 bad request\r\n")
+			return
+		}
+		path := fields[1]
+		size, _ := strconv.Atoi(fields[2])
+		data := make([]byte, size)
+		io.ReadFull(r, data)
+
+		switch path {
+		case "/":
+			io.WriteString(conn, "2 text/gemini\r\n=: /echo Say something\r\n")
+		case "/echo":
+			fmt.Fprintf(conn, "2 text/plain\r\n%s %s", fields[0], data)
+		case "/old":
+			io.WriteString(conn, "3 /\r\n")
+		default:
+			io.WriteString(conn, "4 not found\r\n")
+		}
+	})
+	base := "spartan://" + addr
+
+	client := NewClient()
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/", "=: /echo Say something\r\n"},
+		{"/old", "=: /echo Say something\r\n"},
+		{"/echo?hello%20there", "127.0.0.1 hello there"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := client.Get(base + tt.path)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if !resp.Status.IsSuccess() {
+				t.Fatalf("Expected success, got %d %s", resp.Status, resp.Meta)
+			}
+			body, err := resp.ReadBody()
+			if err != nil {
+				t.Fatalf("ReadBody failed: %v", err)
+			}
+			if string(body) != tt.body {
+				t.Errorf("Expected body %q, got %q", tt.body, string(body))
+			}
+		})
+	}
+
+	resp, err := client.Get(base + "/missing")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if resp.Status != StatusBadRequest || resp.Meta != "not found" {
+		t.Errorf("Expected client error to map to status 59, got %d %s", resp.Status, resp.Meta)
+	}
+}
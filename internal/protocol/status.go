@@ -90,6 +90,38 @@ func (s StatusCode) IsError() bool {
 	return s.IsTemporaryFailure() || s.IsPermanentFailure()
 }
 
+// StatusError is a response status other than success, reported as an error
+type StatusError struct {
+	Status StatusCode
+	Meta   string
+	URL    string
+
+	// Proxy is the proxy the request was sent through ("" if sent directly)
+	Proxy string
+}
+
+// Error returns a description of the failure, explaining proxy failures
+func (e *StatusError) Error() string {
+	switch e.Status {
+	case StatusProxyError:
+		if e.Proxy != "" {
+			return fmt.Sprintf("proxy %s could not fetch the page (status %d): %s", e.Proxy, e.Status, e.Meta)
+		}
+		return fmt.Sprintf("proxy error (status %d): %s", e.Status, e.Meta)
+
+	case StatusProxyRequestRefused:
+		if e.Proxy != "" {
+			return fmt.Sprintf("proxy %s refused to fetch %s (status %d): %s", e.Proxy, e.URL, e.Status, e.Meta)
+		}
+		return fmt.Sprintf("server does not serve or proxy %s (status %d): %s", e.URL, e.Status, e.Meta)
+
+	case StatusSlowDown:
+		return fmt.Sprintf("server asked to slow down (status %d): wait %s", e.Status, ParseSlowDown(e.Meta))
+	}
+
+	return fmt.Sprintf("status %d: %s", e.Status, e.Meta)
+}
+
 // String returns a human-readable description of the status code
 func (s StatusCode) String() string {
 	switch s {
@@ -0,0 +1,128 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTitanMIME is the MIME type sent when an upload does not specify one
+const DefaultTitanMIME = "text/gemini"
+
+// TitanUpload is content to send to a server with the Titan protocol
+type TitanUpload struct {
+	// Body is the content to upload; exactly Size bytes are read from it
+	Body io.Reader
+
+	// Size is the length of the content in bytes
+	Size int64
+
+	// MIME is the content type (defaults to DefaultTitanMIME)
+	MIME string
+
+	// Token is an optional authentication token expected by some servers
+	Token string
+}
+
+// Upload sends content to a titan:// URL and returns the server's response.
+// The request is the URL with size, mime and token parameters appended to
+// its path, followed by the content. Servers usually answer with a redirect
+// to the gemini:// URL of the uploaded resource; redirects are not followed.
+// As with Get, the caller must close the body of a success response
+func (c *Client) Upload(ctx context.Context, rawURL string, upload *TitanUpload) (*Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "titan" {
+		return nil, fmt.Errorf("unsupported URL scheme: %s (expected titan)", u.Scheme)
+	}
+	if upload.Size < 0 {
+		return nil, fmt.Errorf("invalid upload size: %d", upload.Size)
+	}
+
+	host := u.Host
+	if !strings.Contains(host, ":") {
+		host = net.JoinHostPort(host, DefaultPort)
+	}
+
+	conn, closeConn, err := c.dial(ctx, host, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request line, then the content
+	request := titanRequestURL(u, upload) + "\r\n"
+	if _, err := io.WriteString(conn, request); err != nil {
+		closeConn()
+		return nil, fmt.Errorf("failed to send request: %w", contextError(ctx, err))
+	}
+	if _, err := io.CopyN(conn, upload.Body, upload.Size); err != nil {
+		closeConn()
+		return nil, fmt.Errorf("failed to send upload: %w", contextError(ctx, err))
+	}
+
+	// The header deadline starts once the upload has been sent
+	if c.HeaderTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(c.HeaderTimeout))
+	}
+
+	resp, err := ReadResponse(conn, rawURL)
+	if err != nil {
+		closeConn()
+		return nil, fmt.Errorf("failed to read response: %w", timeoutError(ctx, err, ErrHeaderTimeout))
+	}
+	conn.SetReadDeadline(time.Time{})
+	state := conn.ConnectionState()
+	resp.TLSState = &state
+
+	if !resp.Status.IsSuccess() {
+		closeConn()
+		return resp, nil
+	}
+
+	c.attachBody(ctx, resp, conn, closeConn)
+	return resp, nil
+}
+
+// titanRequestURL appends the Titan parameters to the path of u
+func titanRequestURL(u *url.URL, upload *TitanUpload) string {
+	mime := upload.MIME
+	if mime == "" {
+		mime = DefaultTitanMIME
+	}
+
+	base := *u
+	base.RawQuery = ""
+	base.Fragment = ""
+	if base.Path == "" {
+		base.Path = "/"
+	}
+
+	params := fmt.Sprintf(";size=%d;mime=%s", upload.Size, mime)
+	if upload.Token != "" {
+		params += ";token=" + url.PathEscape(upload.Token)
+	}
+
+	return base.String() + params
+}
+
+// TitanURL returns the titan:// URL for uploading to a gemini:// URL
+func TitanURL(geminiURL string) (string, error) {
+	u, err := url.Parse(geminiURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "gemini" {
+		return "", fmt.Errorf("cannot upload to %s URLs", u.Scheme)
+	}
+
+	u.Scheme = "titan"
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String(), nil
+}
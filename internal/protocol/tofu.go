@@ -7,8 +7,11 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -17,13 +20,16 @@ import (
 type TrustLevel string
 
 const (
-	// TrustPermanent means the certificate is permanently trusted
+	// TrustPermanent means the certificate is permanently trusted.
+	// Only permanently trusted certificates are saved to disk
 	TrustPermanent TrustLevel = "permanent"
 
-	// TrustSession means the certificate is trusted for this session only
+	// TrustSession means the certificate is trusted for this session only.
+	// It is held in memory, over any permanently trusted one for the host
 	TrustSession TrustLevel = "session"
 
-	// TrustOnce means the certificate was accepted once but should prompt again
+	// TrustOnce means the certificate was accepted for one connection and
+	// prompts again on the next. It is not recorded at all
 	TrustOnce TrustLevel = "once"
 )
 
@@ -32,6 +38,13 @@ type CertificateInfo struct {
 	// Fingerprint is the SHA256 fingerprint of the certificate
 	Fingerprint string `json:"fingerprint"`
 
+	// KeyFingerprint is the SHA256 fingerprint of the certificate's public
+	// key (its SubjectPublicKeyInfo). A renewed certificate with the same
+	// key matches it, so renewals that keep the key are trusted silently.
+	// Entries pinned before keys were recorded have none until their
+	// certificate is next seen
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+
 	// FirstSeen is when the certificate was first seen
 	FirstSeen time.Time `json:"first_seen"`
 
@@ -46,9 +59,35 @@ type CertificateInfo struct {
 
 	// Subject is the certificate subject
 	Subject string `json:"subject"`
+
+	// Repin replaces this certificate with the next one the server
+	// presents, without asking, keeping when the host was first seen
+	Repin bool `json:"repin,omitempty"`
+}
+
+// KnownHost is a host:port with the certificate trusted for it
+type KnownHost struct {
+	Host string
+	CertificateInfo
+}
+
+// Expired reports whether the certificate had expired at the given time
+func (c *CertificateInfo) Expired(at time.Time) bool {
+	return !c.NotAfter.IsZero() && at.After(c.NotAfter)
 }
 
-// KnownHosts stores the known certificates for each host
+// Matches reports whether a certificate with the given fingerprints is the
+// known one, or a renewal of it that keeps the same public key
+func (c *CertificateInfo) Matches(fingerprint, keyFingerprint string) bool {
+	return c.Fingerprint == fingerprint || (c.KeyFingerprint != "" && c.KeyFingerprint == keyFingerprint)
+}
+
+// knownHostsVersion is the version of the known hosts file format.
+// Version 1.0 keyed hosts by hostname alone; 1.1 keys them by host:port;
+// 1.2 adds public key fingerprints
+const knownHostsVersion = "1.2"
+
+// KnownHosts stores the permanently trusted certificates for each host:port
 type KnownHosts struct {
 	Version string                      `json:"version"`
 	Hosts   map[string]*CertificateInfo `json:"hosts"`
@@ -60,6 +99,10 @@ type TOFUVerifier struct {
 	knownHosts *KnownHosts
 	filePath   string
 
+	// session holds certificates trusted for this session, which take
+	// precedence over knownHosts and are never saved
+	session map[string]*CertificateInfo
+
 	// OnCertificateChange is called when a certificate changes
 	// It should return true to accept the new certificate, false to reject
 	OnCertificateChange func(hostname string, old, new *CertificateInfo) (bool, TrustLevel)
@@ -74,9 +117,10 @@ func NewTOFUVerifier(filePath string) (*TOFUVerifier, error) {
 	verifier := &TOFUVerifier{
 		filePath: filePath,
 		knownHosts: &KnownHosts{
-			Version: "1.0",
+			Version: knownHostsVersion,
 			Hosts:   make(map[string]*CertificateInfo),
 		},
+		session: make(map[string]*CertificateInfo),
 	}
 
 	// Try to load existing known hosts
@@ -90,28 +134,64 @@ func NewTOFUVerifier(filePath string) (*TOFUVerifier, error) {
 	return verifier, nil
 }
 
-// VerifyCertificate verifies a certificate for a given hostname
-func (v *TOFUVerifier) VerifyCertificate(hostname string, state tls.ConnectionState) error {
+// NewMemoryTOFUVerifier creates a verifier that starts with no known hosts
+// and never saves them, for when the known hosts file cannot be used.
+// Certificates are still checked; they are only trusted for the session
+func NewMemoryTOFUVerifier() *TOFUVerifier {
+	return &TOFUVerifier{
+		knownHosts: &KnownHosts{
+			Version: knownHostsVersion,
+			Hosts:   make(map[string]*CertificateInfo),
+		},
+		session: make(map[string]*CertificateInfo),
+	}
+}
+
+// KnownHostKey returns the key a server's certificate is stored under:
+// its lowercased hostname and port. A host without a port stands for the
+// default Gemini port
+func KnownHostKey(host string) string {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = strings.Trim(host, "[]"), DefaultPort
+	}
+	return net.JoinHostPort(strings.ToLower(hostname), port)
+}
+
+// VerifyCertificate verifies the certificate presented by a server.
+// Certificates are pinned per host:port, so capsules on different ports
+// of one host are trusted separately; a host without a port stands for
+// the default Gemini port
+func (v *TOFUVerifier) VerifyCertificate(host string, state tls.ConnectionState) error {
 	if len(state.PeerCertificates) == 0 {
 		return fmt.Errorf("no peer certificates")
 	}
 
+	hostname := KnownHostKey(host)
 	cert := state.PeerCertificates[0]
-	fingerprint := certificateFingerprint(cert)
+	fingerprint := CertificateFingerprint(cert)
+	keyFingerprint := PublicKeyFingerprint(cert)
+	now := time.Now()
 
 	info := &CertificateInfo{
-		Fingerprint: fingerprint,
-		FirstSeen:   time.Now(),
-		LastSeen:    time.Now(),
-		Trust:       TrustPermanent,
-		NotAfter:    cert.NotAfter,
-		Subject:     cert.Subject.String(),
+		Fingerprint:    fingerprint,
+		KeyFingerprint: keyFingerprint,
+		FirstSeen:      now,
+		LastSeen:       now,
+		Trust:          TrustPermanent,
+		NotAfter:       cert.NotAfter,
+		Subject:        cert.Subject.String(),
 	}
 
+	// The lock is not held while asking the callbacks, which may wait for
+	// the user while other requests go on
 	v.mu.Lock()
-	defer v.mu.Unlock()
-
-	known, exists := v.knownHosts.Hosts[hostname]
+	known, exists := v.lookup(hostname)
+	if exists {
+		copied := *known
+		known = &copied
+	}
+	v.mu.Unlock()
 
 	if !exists {
 		// First time seeing this host
@@ -122,23 +202,27 @@ func (v *TOFUVerifier) VerifyCertificate(hostname string, state tls.ConnectionSt
 		}
 
 		if !accept {
-			return fmt.Errorf("certificate rejected by user")
+			return fmt.Errorf("the certificate for %s was rejected", hostname)
 		}
 
-		info.Trust = trustLevel
-		v.knownHosts.Hosts[hostname] = info
-
-		// Save to disk
-		if err := v.save(); err != nil {
-			return fmt.Errorf("failed to save known hosts: %w", err)
-		}
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		return v.trust(hostname, info, trustLevel)
+	}
 
-		return nil
+	// A new certificate for the same key is a renewal: pin it in place of
+	// the old one. So is any new certificate once re-pinning was asked for
+	if known.Fingerprint != fingerprint && (known.Repin || known.Matches(fingerprint, keyFingerprint)) {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		return v.repin(hostname, known, info)
 	}
 
 	// Check if certificate has changed
 	if known.Fingerprint != fingerprint {
-		// Certificate has changed!
+		// Certificate has changed! Replacements of expired certificates are
+		// asked about too; callers can tell them apart with
+		// CertificateInfo.Expired
 		accept, trustLevel := false, TrustOnce
 
 		if v.OnCertificateChange != nil {
@@ -146,23 +230,31 @@ func (v *TOFUVerifier) VerifyCertificate(hostname string, state tls.ConnectionSt
 		}
 
 		if !accept {
-			return fmt.Errorf("certificate changed and was rejected")
+			return fmt.Errorf("the certificate for %s has changed and was rejected", hostname)
 		}
 
 		info.FirstSeen = known.FirstSeen // Preserve first seen time
-		info.Trust = trustLevel
-		v.knownHosts.Hosts[hostname] = info
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		return v.trust(hostname, info, trustLevel)
+	}
 
-		// Save to disk
-		if err := v.save(); err != nil {
-			return fmt.Errorf("failed to save known hosts: %w", err)
-		}
+	v.mu.Lock()
+	defer v.mu.Unlock()
 
+	// Another request may have replaced the entry meanwhile
+	known, exists = v.lookup(hostname)
+	if !exists || known.Fingerprint != fingerprint {
 		return nil
 	}
 
-	// Certificate matches, update last seen time
-	known.LastSeen = time.Now()
+	// Certificate matches, update last seen time and record the key of
+	// certificates pinned before keys were
+	known.LastSeen = now
+	known.Repin = false
+	if known.KeyFingerprint == "" {
+		known.KeyFingerprint = keyFingerprint
+	}
 
 	// Save to disk (we could optimize this to not save on every request)
 	if err := v.save(); err != nil {
@@ -174,12 +266,61 @@ func (v *TOFUVerifier) VerifyCertificate(hostname string, state tls.ConnectionSt
 	return nil
 }
 
-// certificateFingerprint computes the SHA256 fingerprint of a certificate
-func certificateFingerprint(cert *x509.Certificate) string {
+// lookup returns the certificate trusted for a host, preferring one trusted
+// for this session (caller must hold lock)
+func (v *TOFUVerifier) lookup(hostname string) (*CertificateInfo, bool) {
+	if info, ok := v.session[hostname]; ok {
+		return info, true
+	}
+	info, ok := v.knownHosts.Hosts[hostname]
+	return info, ok
+}
+
+// trust records an accepted certificate at a trust level (caller must hold
+// lock). Permanent trust is saved to disk and replaces any session trust;
+// session trust is kept in memory, leaving the permanent entry as it was
+// for later sessions; trusting once records nothing, so the next
+// connection asks again
+func (v *TOFUVerifier) trust(hostname string, info *CertificateInfo, level TrustLevel) error {
+	info.Trust = level
+
+	switch level {
+	case TrustOnce:
+		return nil
+	case TrustSession:
+		v.session[hostname] = info
+		return nil
+	}
+
+	delete(v.session, hostname)
+	v.knownHosts.Hosts[hostname] = info
+	if err := v.save(); err != nil {
+		return fmt.Errorf("failed to save known hosts: %w", err)
+	}
+	return nil
+}
+
+// repin replaces a host's known certificate with a renewal of it, keeping
+// when it was first seen and how much it is trusted (caller must hold lock)
+func (v *TOFUVerifier) repin(hostname string, known, info *CertificateInfo) error {
+	info.FirstSeen = known.FirstSeen
+	return v.trust(hostname, info, known.Trust)
+}
+
+// CertificateFingerprint computes the SHA256 fingerprint of a certificate
+func CertificateFingerprint(cert *x509.Certificate) string {
 	hash := sha256.Sum256(cert.Raw)
 	return hex.EncodeToString(hash[:])
 }
 
+// PublicKeyFingerprint computes the SHA256 fingerprint of a certificate's
+// public key (SPKI), which stays the same when a certificate is renewed
+// with the same key
+func PublicKeyFingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(hash[:])
+}
+
 // Load loads the known hosts from disk
 func (v *TOFUVerifier) Load() error {
 	v.mu.Lock()
@@ -190,11 +331,69 @@ func (v *TOFUVerifier) Load() error {
 		return err
 	}
 
-	return json.Unmarshal(data, v.knownHosts)
+	if err := json.Unmarshal(data, v.knownHosts); err != nil {
+		return err
+	}
+	if v.knownHosts.Hosts == nil {
+		v.knownHosts.Hosts = make(map[string]*CertificateInfo)
+	}
+	v.migrate()
+
+	// Older versions saved every entry; only permanent trust outlives a
+	// session
+	for host, info := range v.knownHosts.Hosts {
+		switch info.Trust {
+		case TrustPermanent:
+		case "":
+			info.Trust = TrustPermanent
+		default:
+			delete(v.knownHosts.Hosts, host)
+		}
+	}
+	return nil
 }
 
-// save saves the known hosts to disk (caller must hold lock)
+// migrate upgrades known hosts loaded from an older file (caller must hold
+// lock). The file is rewritten the next time it is saved
+func (v *TOFUVerifier) migrate() {
+	switch v.knownHosts.Version {
+	case knownHostsVersion:
+		return
+	case "", "1.0":
+		v.migrateHostnameKeys()
+	}
+
+	// Files before 1.2 hold only certificate fingerprints. A key cannot be
+	// recovered from a hash of its certificate, so each entry's key
+	// fingerprint is recorded the next time its certificate is seen;
+	// until then a renewal warns as before
+	v.knownHosts.Version = knownHostsVersion
+}
+
+// migrateHostnameKeys rekeys hosts from version 1.0, which keyed them by
+// hostname. Their certificates were almost always seen on the default
+// port, so they are kept for that port (caller must hold lock)
+func (v *TOFUVerifier) migrateHostnameKeys() {
+	hosts := make(map[string]*CertificateInfo, len(v.knownHosts.Hosts))
+	for host, info := range v.knownHosts.Hosts {
+		key := KnownHostKey(host)
+		// An entry already keyed by host:port wins over a legacy one
+		if _, exists := hosts[key]; exists && key != host {
+			continue
+		}
+		hosts[key] = info
+	}
+	v.knownHosts.Hosts = hosts
+}
+
+// save saves the known hosts to disk (caller must hold lock). The file is
+// written beside the old one and renamed over it, so that a crash part way
+// through never leaves a truncated file behind
 func (v *TOFUVerifier) save() error {
+	if v.filePath == "" {
+		return nil
+	}
+
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(v.filePath)
 	if err := os.MkdirAll(dir, 0700); err != nil {
@@ -207,8 +406,24 @@ func (v *TOFUVerifier) save() error {
 		return err
 	}
 
-	// Write to file
-	return os.WriteFile(v.filePath, data, 0600)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(v.filePath)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), v.filePath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
 }
 
 // Save saves the known hosts to disk (thread-safe version)
@@ -218,21 +433,64 @@ func (v *TOFUVerifier) Save() error {
 	return v.save()
 }
 
-// GetCertificateInfo returns information about a known certificate
-func (v *TOFUVerifier) GetCertificateInfo(hostname string) (*CertificateInfo, bool) {
+// GetCertificateInfo returns information about the certificate trusted for
+// a host:port, for this session or permanently
+func (v *TOFUVerifier) GetCertificateInfo(host string) (*CertificateInfo, bool) {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
 
-	info, exists := v.knownHosts.Hosts[hostname]
-	return info, exists
+	return v.lookup(KnownHostKey(host))
+}
+
+// List returns every host:port with a trusted certificate, sorted by host.
+// Certificates trusted for this session are listed in place of permanent
+// ones
+func (v *TOFUVerifier) List() []KnownHost {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	hosts := make([]KnownHost, 0, len(v.knownHosts.Hosts)+len(v.session))
+	for host, info := range v.knownHosts.Hosts {
+		if _, ok := v.session[host]; !ok {
+			hosts = append(hosts, KnownHost{Host: host, CertificateInfo: *info})
+		}
+	}
+	for host, info := range v.session {
+		hosts = append(hosts, KnownHost{Host: host, CertificateInfo: *info})
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
+
+// RepinCertificate makes the next certificate a host:port presents replace
+// the trusted one without asking, as after a server's key has been
+// replaced on purpose
+func (v *TOFUVerifier) RepinCertificate(host string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	hostname := KnownHostKey(host)
+	info, ok := v.lookup(hostname)
+	if !ok {
+		return fmt.Errorf("no certificate is known for %s", hostname)
+	}
+	info.Repin = true
+	if info.Trust != TrustPermanent {
+		return nil
+	}
+	return v.save()
 }
 
-// RemoveCertificate removes a certificate from the known hosts
-func (v *TOFUVerifier) RemoveCertificate(hostname string) error {
+// RemoveCertificate removes the certificate for a host:port from the
+// known hosts
+func (v *TOFUVerifier) RemoveCertificate(host string) error {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
-	delete(v.knownHosts.Hosts, hostname)
+	delete(v.knownHosts.Hosts, KnownHostKey(host))
+	delete(v.session, KnownHostKey(host))
 	return v.save()
 }
 
@@ -242,5 +500,6 @@ func (v *TOFUVerifier) ClearAll() error {
 	defer v.mu.Unlock()
 
 	v.knownHosts.Hosts = make(map[string]*CertificateInfo)
+	v.session = make(map[string]*CertificateInfo)
 	return v.save()
 }
@@ -0,0 +1,474 @@
+package protocol
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCertificate creates a self-signed server certificate with a new key,
+// valid between notBefore and notAfter
+func testCertificate(t *testing.T, notBefore, notAfter time.Time) tls.ConnectionState {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	return testCertificateWithKey(t, key, notBefore, notAfter)
+}
+
+// testCertificateWithKey creates a self-signed server certificate for key
+func testCertificateWithKey(t *testing.T, key *ecdsa.PrivateKey, notBefore, notAfter time.Time) tls.ConnectionState {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	return tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+}
+
+// listedHosts returns the host:port of each certificate v lists
+func listedHosts(v *TOFUVerifier) []string {
+	var hosts []string
+	for _, known := range v.List() {
+		hosts = append(hosts, known.Host)
+	}
+	return hosts
+}
+
+func TestKnownHostKey(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "example.com:1965"},
+		{"Example.COM:1965", "example.com:1965"},
+		{"example.com:1966", "example.com:1966"},
+		{"::1", "[::1]:1965"},
+		{"[::1]:1966", "[::1]:1966"},
+	}
+
+	for _, tt := range tests {
+		if got := KnownHostKey(tt.host); got != tt.want {
+			t.Errorf("KnownHostKey(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestTOFUPerPort(t *testing.T) {
+	v, err := NewTOFUVerifier(filepath.Join(t.TempDir(), "known_hosts.json"))
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+
+	now := time.Now()
+	first := testCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))
+	second := testCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))
+
+	if err := v.VerifyCertificate("example.com:1965", first); err != nil {
+		t.Fatalf("Expected first certificate to be trusted: %v", err)
+	}
+	if err := v.VerifyCertificate("example.com:1966", second); err != nil {
+		t.Fatalf("Expected a different port to be trusted separately: %v", err)
+	}
+	if err := v.VerifyCertificate("example.com:1965", first); err != nil {
+		t.Errorf("Expected known certificate to be accepted: %v", err)
+	}
+	if err := v.VerifyCertificate("example.com", second); err == nil {
+		t.Error("Expected changed certificate on the default port to be rejected")
+	}
+
+	if hosts := listedHosts(v); len(hosts) != 2 {
+		t.Errorf("Expected 2 known hosts, got %v", hosts)
+	}
+}
+
+func TestTOFUExpiredChangeAsked(t *testing.T) {
+	v, err := NewTOFUVerifier(filepath.Join(t.TempDir(), "known_hosts.json"))
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+
+	now := time.Now()
+	expired := testCertificate(t, now.Add(-48*time.Hour), now.Add(-time.Hour))
+	renewed := testCertificate(t, now.Add(-time.Hour), now.Add(24*time.Hour))
+
+	if err := v.VerifyCertificate("example.com", expired); err != nil {
+		t.Fatalf("Expected first certificate to be trusted: %v", err)
+	}
+	firstSeen := v.knownHosts.Hosts["example.com:1965"].FirstSeen
+
+	// Replacing an expired certificate is asked about like any other change
+	var asked bool
+	v.OnCertificateChange = func(host string, old, new *CertificateInfo) (bool, TrustLevel) {
+		asked = true
+		if !old.Expired(now) {
+			t.Error("Expected the known certificate to be expired")
+		}
+		return false, TrustOnce
+	}
+	if err := v.VerifyCertificate("example.com", renewed); err == nil || !asked {
+		t.Errorf("Expected renewal to be asked about and rejected, got %v (asked: %v)", err, asked)
+	}
+
+	// An accepted renewal is pinned, keeping when the host was first seen
+	v.OnCertificateChange = func(host string, old, new *CertificateInfo) (bool, TrustLevel) {
+		return true, TrustPermanent
+	}
+	if err := v.VerifyCertificate("example.com", renewed); err != nil {
+		t.Fatalf("Expected accepted renewal to be trusted: %v", err)
+	}
+	info, _ := v.GetCertificateInfo("example.com")
+	if info.Fingerprint != CertificateFingerprint(renewed.PeerCertificates[0]) {
+		t.Error("Expected renewed certificate to be pinned")
+	}
+	if !info.FirstSeen.Equal(firstSeen) {
+		t.Errorf("Expected first seen to be kept, got %v", info.FirstSeen)
+	}
+}
+
+func TestTOFUMigrateHostnameKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts.json")
+	legacy := `{
+  "version": "1.0",
+  "hosts": {
+    "Example.com": {"fingerprint": "aa", "trust": "permanent"},
+    "other.example": {"fingerprint": "bb", "trust": "permanent"},
+    "other.example:1965": {"fingerprint": "cc", "trust": "permanent"}
+  }
+}`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	v, err := NewTOFUVerifier(path)
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+
+	hosts := listedHosts(v)
+	if len(hosts) != 2 || hosts[0] != "example.com:1965" || hosts[1] != "other.example:1965" {
+		t.Fatalf("Expected hosts keyed by host:port, got %v", hosts)
+	}
+	if info, _ := v.GetCertificateInfo("other.example:1965"); info.Fingerprint != "cc" {
+		t.Errorf("Expected host:port entry to win over the legacy one, got %s", info.Fingerprint)
+	}
+
+	if err := v.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	v, err = NewTOFUVerifier(path)
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+	if v.knownHosts.Version != knownHostsVersion || len(listedHosts(v)) != 2 {
+		t.Errorf("Expected migrated file to be saved, got version %s with %v", v.knownHosts.Version, listedHosts(v))
+	}
+}
+
+func TestTOFUPublicKeyPinning(t *testing.T) {
+	v, err := NewTOFUVerifier(filepath.Join(t.TempDir(), "known_hosts.json"))
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+	v.OnCertificateChange = func(host string, old, new *CertificateInfo) (bool, TrustLevel) {
+		return false, TrustOnce
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	now := time.Now()
+	original := testCertificateWithKey(t, key, now.Add(-time.Hour), now.Add(24*time.Hour))
+	renewed := testCertificateWithKey(t, key, now, now.Add(48*time.Hour))
+	rekeyed := testCertificate(t, now, now.Add(48*time.Hour))
+
+	if err := v.VerifyCertificate("example.com", original); err != nil {
+		t.Fatalf("Expected first certificate to be trusted: %v", err)
+	}
+
+	// A renewal with the same key passes and is pinned
+	if err := v.VerifyCertificate("example.com", renewed); err != nil {
+		t.Fatalf("Expected renewal with the same key to be accepted: %v", err)
+	}
+	info, _ := v.GetCertificateInfo("example.com")
+	if info.Fingerprint != CertificateFingerprint(renewed.PeerCertificates[0]) {
+		t.Error("Expected renewed certificate to be pinned")
+	}
+	if info.KeyFingerprint != PublicKeyFingerprint(original.PeerCertificates[0]) {
+		t.Error("Expected key fingerprint to be unchanged")
+	}
+
+	// A new key still warns
+	if err := v.VerifyCertificate("example.com", rekeyed); err == nil {
+		t.Error("Expected certificate with a new key to be rejected")
+	}
+}
+
+func TestTOFUMigrateKeyFingerprints(t *testing.T) {
+	now := time.Now()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	original := testCertificateWithKey(t, key, now.Add(-time.Hour), now.Add(24*time.Hour))
+	renewed := testCertificateWithKey(t, key, now, now.Add(48*time.Hour))
+
+	// A file from before key fingerprints were recorded
+	path := filepath.Join(t.TempDir(), "known_hosts.json")
+	legacy := `{"version": "1.1", "hosts": {"example.com:1965": {"fingerprint": "` +
+		CertificateFingerprint(original.PeerCertificates[0]) + `", "trust": "permanent"}}}`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	v, err := NewTOFUVerifier(path)
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+	if v.knownHosts.Version != knownHostsVersion {
+		t.Errorf("Expected version %s, got %s", knownHostsVersion, v.knownHosts.Version)
+	}
+
+	// Seeing the pinned certificate records its key...
+	if err := v.VerifyCertificate("example.com", original); err != nil {
+		t.Fatalf("Expected known certificate to be accepted: %v", err)
+	}
+	if info, _ := v.GetCertificateInfo("example.com"); info.KeyFingerprint == "" {
+		t.Fatal("Expected key fingerprint to be recorded")
+	}
+
+	// ...so that a later renewal with the same key passes
+	if err := v.VerifyCertificate("example.com", renewed); err != nil {
+		t.Errorf("Expected renewal with the same key to be accepted: %v", err)
+	}
+}
+
+func TestTOFUTrustLevels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts.json")
+	v, err := NewTOFUVerifier(path)
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+
+	now := time.Now()
+	pinned := testCertificate(t, now.Add(-time.Hour), now.Add(24*time.Hour))
+	changed := testCertificate(t, now.Add(-time.Hour), now.Add(24*time.Hour))
+
+	var asked int
+	level := TrustOnce
+	v.OnFirstSeen = func(host string, info *CertificateInfo) (bool, TrustLevel) {
+		asked++
+		return true, level
+	}
+	v.OnCertificateChange = func(host string, old, new *CertificateInfo) (bool, TrustLevel) {
+		asked++
+		return true, level
+	}
+
+	// Trusting once records nothing, so the next connection asks again
+	v.VerifyCertificate("once.example", pinned)
+	v.VerifyCertificate("once.example", pinned)
+	if asked != 2 {
+		t.Errorf("Expected to be asked on each connection, asked %d times", asked)
+	}
+	if _, ok := v.GetCertificateInfo("once.example"); ok {
+		t.Error("Expected certificate trusted once not to be recorded")
+	}
+
+	// Session trust is remembered in memory only
+	asked, level = 0, TrustSession
+	v.VerifyCertificate("session.example", pinned)
+	v.VerifyCertificate("session.example", pinned)
+	if asked != 1 {
+		t.Errorf("Expected to be asked once for the session, asked %d times", asked)
+	}
+
+	// Accepting a change for the session keeps the permanent pin on disk
+	level = TrustPermanent
+	v.VerifyCertificate("example.com", pinned)
+	level = TrustSession
+	if err := v.VerifyCertificate("example.com", changed); err != nil {
+		t.Fatalf("Expected change to be accepted: %v", err)
+	}
+	if info, _ := v.GetCertificateInfo("example.com"); info.Trust != TrustSession {
+		t.Errorf("Expected session trust in this session, got %s", info.Trust)
+	}
+
+	v, err = NewTOFUVerifier(path)
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+	if hosts := listedHosts(v); len(hosts) != 1 || hosts[0] != "example.com:1965" {
+		t.Fatalf("Expected only the permanent entry on disk, got %v", hosts)
+	}
+	info, _ := v.GetCertificateInfo("example.com")
+	if info.Fingerprint != CertificateFingerprint(pinned.PeerCertificates[0]) {
+		t.Error("Expected the permanently trusted certificate to be kept")
+	}
+}
+
+func TestTOFULoadDropsTransientTrust(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts.json")
+	old := `{"version": "1.2", "hosts": {
+  "permanent.example:1965": {"fingerprint": "aa", "trust": "permanent"},
+  "session.example:1965": {"fingerprint": "bb", "trust": "session"},
+  "once.example:1965": {"fingerprint": "cc", "trust": "once"}
+}}`
+	if err := os.WriteFile(path, []byte(old), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	v, err := NewTOFUVerifier(path)
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+	if hosts := listedHosts(v); len(hosts) != 1 || hosts[0] != "permanent.example:1965" {
+		t.Errorf("Expected only permanent trust to be loaded, got %v", hosts)
+	}
+}
+
+func TestTOFUCallbacksDoNotHoldLock(t *testing.T) {
+	v, err := NewTOFUVerifier(filepath.Join(t.TempDir(), "known_hosts.json"))
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+
+	now := time.Now()
+	v.VerifyCertificate("example.com", testCertificate(t, now.Add(-time.Hour), now.Add(time.Hour)))
+
+	// A prompt waiting for the user must not block other lookups
+	v.OnCertificateChange = func(host string, old, new *CertificateInfo) (bool, TrustLevel) {
+		if _, ok := v.GetCertificateInfo(host); !ok {
+			t.Error("Expected known certificate during the callback")
+		}
+		return true, TrustPermanent
+	}
+	if err := v.VerifyCertificate("example.com", testCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))); err != nil {
+		t.Errorf("Expected change to be accepted: %v", err)
+	}
+}
+
+func TestTOFUListAndRepin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts.json")
+	v, err := NewTOFUVerifier(path)
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+	v.OnCertificateChange = func(host string, old, new *CertificateInfo) (bool, TrustLevel) {
+		return false, TrustOnce
+	}
+
+	now := time.Now()
+	original := testCertificate(t, now.Add(-time.Hour), now.Add(24*time.Hour))
+	replaced := testCertificate(t, now.Add(-time.Hour), now.Add(24*time.Hour))
+	v.VerifyCertificate("b.example", original)
+	v.VerifyCertificate("a.example:1966", original)
+
+	list := v.List()
+	if len(list) != 2 || list[0].Host != "a.example:1966" || list[1].Host != "b.example:1965" {
+		t.Fatalf("Expected hosts sorted by host, got %+v", list)
+	}
+	if list[1].Fingerprint != CertificateFingerprint(original.PeerCertificates[0]) || list[1].Trust != TrustPermanent {
+		t.Errorf("Expected certificate details, got %+v", list[1])
+	}
+
+	if err := v.RepinCertificate("missing.example"); err == nil {
+		t.Error("Expected error re-pinning an unknown host")
+	}
+	if err := v.RepinCertificate("b.example"); err != nil {
+		t.Fatalf("RepinCertificate failed: %v", err)
+	}
+
+	// The flag is saved, so re-pinning survives a restart
+	v, err = NewTOFUVerifier(path)
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+	if info, _ := v.GetCertificateInfo("b.example"); !info.Repin {
+		t.Fatal("Expected re-pin to be saved")
+	}
+
+	// The next certificate is trusted without asking, keeping first seen
+	firstSeen := v.List()[1].FirstSeen
+	if err := v.VerifyCertificate("b.example", replaced); err != nil {
+		t.Fatalf("Expected new certificate to be pinned: %v", err)
+	}
+	info, _ := v.GetCertificateInfo("b.example")
+	if info.Repin || info.Fingerprint != CertificateFingerprint(replaced.PeerCertificates[0]) {
+		t.Errorf("Expected new certificate to be pinned, got %+v", info)
+	}
+	if !info.FirstSeen.Equal(firstSeen) {
+		t.Errorf("Expected first seen to be kept, got %v", info.FirstSeen)
+	}
+
+	// And the one after that is checked as usual
+	if err := v.VerifyCertificate("b.example", original); err == nil {
+		t.Error("Expected later change to be rejected")
+	}
+}
+
+func TestTOFUSaveReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "known_hosts.json")
+
+	v, err := NewTOFUVerifier(path)
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+	now := time.Now()
+	if err := v.VerifyCertificate("example.com", testCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))); err != nil {
+		t.Fatalf("VerifyCertificate failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "known_hosts.json" {
+		t.Errorf("Expected only the known hosts file, got %v", entries)
+	}
+
+	loaded, err := NewTOFUVerifier(path)
+	if err != nil {
+		t.Fatalf("NewTOFUVerifier failed: %v", err)
+	}
+	if _, ok := loaded.GetCertificateInfo("example.com"); !ok {
+		t.Error("Expected saved host to be loaded")
+	}
+}
+
+func TestMemoryTOFUVerifier(t *testing.T) {
+	v := NewMemoryTOFUVerifier()
+	now := time.Now()
+	first := testCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))
+	changed := testCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))
+
+	if err := v.VerifyCertificate("example.com", first); err != nil {
+		t.Fatalf("Expected first certificate to be trusted: %v", err)
+	}
+	if err := v.VerifyCertificate("example.com", changed); err == nil {
+		t.Error("Expected a changed certificate to be rejected")
+	}
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Bookmark is a saved page
+type Bookmark struct {
+	// URL is the bookmarked URL
+	URL string `json:"url"`
+
+	// Title is the page title when it was bookmarked (may be empty)
+	Title string `json:"title,omitempty"`
+
+	// Added is when the bookmark was created
+	Added time.Time `json:"added"`
+}
+
+// bookmarksFile is the on-disk format of the bookmarks file
+type bookmarksFile struct {
+	Version   string      `json:"version"`
+	Bookmarks []*Bookmark `json:"bookmarks"`
+}
+
+// Bookmarks is an ordered list of bookmarks, optionally persisted to a file
+type Bookmarks struct {
+	mu    sync.RWMutex
+	items []*Bookmark
+
+	// path is where bookmarks are persisted ("" keeps them in memory only)
+	path string
+}
+
+// NewBookmarks creates an empty, in-memory bookmark list
+func NewBookmarks() *Bookmarks {
+	return &Bookmarks{}
+}
+
+// LoadBookmarks creates a bookmark list persisted at path, loading any
+// bookmarks already stored there
+func LoadBookmarks(path string) (*Bookmarks, error) {
+	b := &Bookmarks{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return b, nil
+		}
+		return nil, fmt.Errorf("failed to read bookmarks: %w", err)
+	}
+
+	var file bookmarksFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse bookmarks: %w", err)
+	}
+	b.items = file.Bookmarks
+
+	return b, nil
+}
+
+// Add bookmarks a URL, or updates the title of an existing bookmark
+func (b *Bookmarks) Add(url, title string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if i := b.index(url); i >= 0 {
+		b.items[i].Title = title
+	} else {
+		b.items = append(b.items, &Bookmark{URL: url, Title: title, Added: time.Now()})
+	}
+	return b.save()
+}
+
+// Remove deletes the bookmark for a URL
+func (b *Bookmarks) Remove(url string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.index(url)
+	if i < 0 {
+		return fmt.Errorf("no bookmark for %s", url)
+	}
+	b.items = append(b.items[:i], b.items[i+1:]...)
+	return b.save()
+}
+
+// Move points the bookmark for a URL at a new URL, keeping its title and
+// when it was added, as when the page has permanently moved. If the new URL
+// is already bookmarked the old bookmark is dropped. It reports whether
+// there was a bookmark to move
+func (b *Bookmarks) Move(from, to string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.index(from)
+	if i < 0 || from == to {
+		return false, nil
+	}
+	if b.index(to) >= 0 {
+		b.items = append(b.items[:i], b.items[i+1:]...)
+	} else {
+		b.items[i].URL = to
+	}
+	return true, b.save()
+}
+
+// Contains reports whether a URL is bookmarked
+func (b *Bookmarks) Contains(url string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.index(url) >= 0
+}
+
+// List returns the bookmarks in the order they were added
+func (b *Bookmarks) List() []Bookmark {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	list := make([]Bookmark, len(b.items))
+	for i, item := range b.items {
+		list[i] = *item
+	}
+	return list
+}
+
+// index returns the position of the bookmark for url, or -1 (caller must hold lock)
+func (b *Bookmarks) index(url string) int {
+	for i, item := range b.items {
+		if item.URL == url {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the bookmarks to disk (caller must hold lock)
+func (b *Bookmarks) save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(bookmarksFile{Version: "1.0", Bookmarks: b.items}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(b.path, data, 0600)
+}
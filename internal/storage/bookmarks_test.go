@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBookmarks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bookmarks.json")
+
+	b, err := LoadBookmarks(path)
+	if err != nil {
+		t.Fatalf("LoadBookmarks failed: %v", err)
+	}
+
+	if err := b.Add("gemini://example.com/", "Example"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := b.Add("gemini://example.org/", ""); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := b.Add("gemini://example.com/", "Renamed"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	if !b.Contains("gemini://example.com/") {
+		t.Error("Expected example.com to be bookmarked")
+	}
+
+	// Reload from disk
+	b, err = LoadBookmarks(path)
+	if err != nil {
+		t.Fatalf("LoadBookmarks failed: %v", err)
+	}
+
+	list := b.List()
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 bookmarks, got %d", len(list))
+	}
+	if list[0].URL != "gemini://example.com/" || list[0].Title != "Renamed" {
+		t.Errorf("Expected updated first bookmark, got %+v", list[0])
+	}
+
+	if err := b.Remove("gemini://example.com/"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := b.Remove("gemini://example.com/"); err == nil {
+		t.Error("Expected error removing a missing bookmark")
+	}
+	if b.Contains("gemini://example.com/") {
+		t.Error("Expected example.com to be removed")
+	}
+}
+
+func TestBookmarksMove(t *testing.T) {
+	b := NewBookmarks()
+	b.Add("gemini://old.example/", "Old")
+	b.Add("gemini://other.example/", "Other")
+	b.Add("gemini://new.example/other", "Other moved")
+
+	moved, err := b.Move("gemini://old.example/", "gemini://new.example/")
+	if err != nil || !moved {
+		t.Fatalf("Expected bookmark to move, got %v, %v", moved, err)
+	}
+	list := b.List()
+	if list[0].URL != "gemini://new.example/" || list[0].Title != "Old" {
+		t.Errorf("Expected moved bookmark to keep its title, got %+v", list[0])
+	}
+
+	// Moving onto an existing bookmark drops the old one
+	if moved, _ := b.Move("gemini://other.example/", "gemini://new.example/other"); !moved {
+		t.Error("Expected bookmark to move")
+	}
+	if len(b.List()) != 2 || b.Contains("gemini://other.example/") {
+		t.Errorf("Expected duplicate bookmark to be dropped, got %+v", b.List())
+	}
+
+	if moved, _ := b.Move("gemini://missing.example/", "gemini://new.example/"); moved {
+		t.Error("Expected no bookmark to move")
+	}
+}
@@ -0,0 +1,359 @@
+package storage
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/watson-ij/gemini/internal/protocol"
+)
+
+const (
+	// DefaultCacheEntries is the default number of pages kept in memory
+	DefaultCacheEntries = 100
+
+	// DefaultCacheMaxAge is the default time a cached page stays usable
+	DefaultCacheMaxAge = 24 * time.Hour
+
+	// cacheFileExt is the file extension of pages in the disk cache
+	cacheFileExt = ".json"
+)
+
+// CacheEntry is a fetched page kept for history navigation
+type CacheEntry struct {
+	// URL is the normalized URL of the page
+	URL string `json:"url"`
+
+	// Meta is the meta field of the success response (MIME type and parameters)
+	Meta string `json:"meta"`
+
+	// Body is the page content
+	Body []byte `json:"body"`
+
+	// Fetched is when the page was fetched
+	Fetched time.Time `json:"fetched"`
+
+	// Private entries, such as pages fetched with a client certificate,
+	// are kept in memory only
+	Private bool `json:"-"`
+}
+
+// CacheOptions configures a Cache
+type CacheOptions struct {
+	// MaxEntries is the number of pages kept in memory (0 = DefaultCacheEntries)
+	MaxEntries int
+
+	// MaxAge is how long a page stays usable (0 = DefaultCacheMaxAge)
+	MaxAge time.Duration
+
+	// Dir is where pages are also stored on disk ("" = memory only)
+	Dir string
+
+	// MaxDiskBytes limits the size of the disk cache (0 = no limit).
+	// The oldest pages are removed first
+	MaxDiskBytes int64
+}
+
+// CacheStats describes the contents of a Cache
+type CacheStats struct {
+	Entries     int
+	Bytes       int64
+	DiskEntries int
+	DiskBytes   int64
+}
+
+// Cache is an LRU cache of pages keyed on normalized URL, optionally
+// backed by a directory on disk so that pages survive restarts
+type Cache struct {
+	mu      sync.Mutex
+	opts    CacheOptions
+	order   *list.List // of *CacheEntry, most recently used first
+	entries map[string]*list.Element
+	bytes   int64
+}
+
+// NewCache creates a page cache
+func NewCache(opts CacheOptions) *Cache {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultCacheEntries
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefaultCacheMaxAge
+	}
+
+	return &Cache{
+		opts:    opts,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached page for a URL if there is one that has not expired
+func (c *Cache) Get(rawURL string) (*CacheEntry, bool) {
+	key := NormalizeURL(rawURL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*CacheEntry)
+		if c.expired(entry) {
+			c.removeElement(el)
+			c.removeFile(key)
+			return nil, false
+		}
+		c.order.MoveToFront(el)
+		return entry, true
+	}
+
+	entry, ok := c.readFile(key)
+	if !ok {
+		return nil, false
+	}
+	if c.expired(entry) {
+		c.removeFile(key)
+		return nil, false
+	}
+
+	c.add(entry)
+	return entry, true
+}
+
+// Put stores a page, replacing any cached copy of the same URL
+func (c *Cache) Put(entry *CacheEntry) error {
+	entry.URL = NormalizeURL(entry.URL)
+	if entry.Fetched.IsZero() {
+		entry.Fetched = time.Now()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[entry.URL]; ok {
+		c.removeElement(el)
+	}
+	c.add(entry)
+
+	if entry.Private {
+		c.removeFile(entry.URL)
+		return nil
+	}
+	return c.writeFile(entry)
+}
+
+// Remove drops the cached page for a URL
+func (c *Cache) Remove(rawURL string) {
+	key := NormalizeURL(rawURL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+	c.removeFile(key)
+}
+
+// Clear empties the cache, including the disk cache
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.bytes = 0
+
+	if c.opts.Dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(c.opts.Dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Stats returns the number and size of cached pages
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{Entries: c.order.Len(), Bytes: c.bytes}
+	for _, f := range c.diskFiles() {
+		stats.DiskEntries++
+		stats.DiskBytes += f.size
+	}
+	return stats
+}
+
+// add puts an entry at the front of the LRU list, evicting the least
+// recently used entries beyond the limit (caller must hold lock)
+func (c *Cache) add(entry *CacheEntry) {
+	c.entries[entry.URL] = c.order.PushFront(entry)
+	c.bytes += int64(len(entry.Body))
+
+	for c.order.Len() > c.opts.MaxEntries {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// removeElement drops an entry from memory (caller must hold lock)
+func (c *Cache) removeElement(el *list.Element) {
+	entry := c.order.Remove(el).(*CacheEntry)
+	delete(c.entries, entry.URL)
+	c.bytes -= int64(len(entry.Body))
+}
+
+// expired reports whether an entry is too old to use
+func (c *Cache) expired(entry *CacheEntry) bool {
+	return time.Since(entry.Fetched) > c.opts.MaxAge
+}
+
+// cacheFile is a page in the disk cache
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// filePath returns where the page for a normalized URL is stored on disk
+func (c *Cache) filePath(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(c.opts.Dir, hex.EncodeToString(hash[:])+cacheFileExt)
+}
+
+// readFile loads a page from the disk cache (caller must hold lock)
+func (c *Cache) readFile(key string) (*CacheEntry, bool) {
+	if c.opts.Dir == "" {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.filePath(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != key {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// writeFile stores a page in the disk cache and trims the cache to its
+// size limit (caller must hold lock)
+func (c *Cache) writeFile(entry *CacheEntry) error {
+	if c.opts.Dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.opts.Dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.filePath(entry.URL), data, 0600); err != nil {
+		return err
+	}
+
+	c.trimDisk()
+	return nil
+}
+
+// removeFile deletes a page from the disk cache (caller must hold lock)
+func (c *Cache) removeFile(key string) {
+	if c.opts.Dir != "" {
+		os.Remove(c.filePath(key))
+	}
+}
+
+// trimDisk removes expired pages, then the oldest pages until the disk
+// cache fits its size limit (caller must hold lock)
+func (c *Cache) trimDisk() {
+	files := c.diskFiles()
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	for _, f := range files {
+		tooBig := c.opts.MaxDiskBytes > 0 && total > c.opts.MaxDiskBytes
+		if !tooBig && time.Since(f.modTime) <= c.opts.MaxAge {
+			continue
+		}
+		if os.Remove(f.path) == nil {
+			total -= f.size
+		}
+	}
+}
+
+// diskFiles lists the pages in the disk cache (caller must hold lock)
+func (c *Cache) diskFiles() []cacheFile {
+	if c.opts.Dir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(c.opts.Dir)
+	if err != nil {
+		return nil
+	}
+
+	var files []cacheFile
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != cacheFileExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{
+			path:    filepath.Join(c.opts.Dir, entry.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	return files
+}
+
+// NormalizeURL returns the form of a URL used as its cache key: the scheme
+// and host are lowercased, the default port and fragment are dropped and
+// an empty path becomes "/"
+func NormalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Opaque != "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != protocol.SchemePort(u.Scheme) {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+
+	if u.Path == "" && u.Host != "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"gemini://Example.COM", "gemini://example.com/"},
+		{"gemini://example.com:1965/page#top", "gemini://example.com/page"},
+		{"gemini://example.com:1966/page", "gemini://example.com:1966/page"},
+		{"GOPHER://example.com:70/1/", "gopher://example.com/1/"},
+		{"gemini://example.com/search?q", "gemini://example.com/search?q"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeURL(tt.input); got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCacheLRU(t *testing.T) {
+	c := NewCache(CacheOptions{MaxEntries: 2})
+
+	for _, u := range []string{"gemini://a/", "gemini://b/"} {
+		c.Put(&CacheEntry{URL: u, Meta: "text/gemini", Body: []byte(u)})
+	}
+
+	// Using a makes b the least recently used
+	if _, ok := c.Get("gemini://A:1965"); !ok {
+		t.Fatal("Expected a to be cached under its normalized URL")
+	}
+	c.Put(&CacheEntry{URL: "gemini://c/", Body: []byte("c")})
+
+	if _, ok := c.Get("gemini://b/"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if _, ok := c.Get("gemini://a/"); !ok {
+		t.Error("Expected a to stay cached")
+	}
+	if stats := c.Stats(); stats.Entries != 2 {
+		t.Errorf("Expected 2 entries, got %d", stats.Entries)
+	}
+}
+
+func TestCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(CacheOptions{Dir: dir, MaxAge: time.Hour})
+
+	c.Put(&CacheEntry{URL: "gemini://example.com/", Meta: "text/gemini", Body: []byte("# Hello\n")})
+	c.Put(&CacheEntry{URL: "gemini://example.com/private", Body: []byte("secret"), Private: true})
+	c.Put(&CacheEntry{URL: "gemini://example.com/old", Body: []byte("old"), Fetched: time.Now().Add(-2 * time.Hour)})
+
+	// A new cache over the same directory sees the public, unexpired page
+	c = NewCache(CacheOptions{Dir: dir, MaxAge: time.Hour})
+	entry, ok := c.Get("gemini://example.com/")
+	if !ok {
+		t.Fatal("Expected page to be loaded from disk")
+	}
+	if entry.Meta != "text/gemini" || string(entry.Body) != "# Hello\n" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if _, ok := c.Get("gemini://example.com/private"); ok {
+		t.Error("Expected private page not to be stored on disk")
+	}
+	if _, ok := c.Get("gemini://example.com/old"); ok {
+		t.Error("Expected expired page to be ignored")
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if _, ok := c.Get("gemini://example.com/"); ok {
+		t.Error("Expected cache to be empty after Clear")
+	}
+}
+
+func TestCacheDiskLimit(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(CacheOptions{Dir: dir, MaxDiskBytes: 600})
+
+	body := make([]byte, 200)
+	for _, u := range []string{"gemini://example.com/1", "gemini://example.com/2", "gemini://example.com/3"} {
+		c.Put(&CacheEntry{URL: u, Body: body})
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := c.Stats()
+	if stats.DiskBytes > 600 {
+		t.Errorf("Expected disk cache within 600 bytes, got %d", stats.DiskBytes)
+	}
+	if stats.DiskEntries == 0 || stats.DiskEntries == 3 {
+		t.Errorf("Expected the oldest pages to be removed, got %d on disk", stats.DiskEntries)
+	}
+}
@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DownloadState is the progress of a download
+type DownloadState string
+
+const (
+	// DownloadActive is a download still receiving data
+	DownloadActive DownloadState = "active"
+
+	// DownloadDone is a download saved in full
+	DownloadDone DownloadState = "done"
+
+	// DownloadFailed is a download that stopped with an error
+	DownloadFailed DownloadState = "failed"
+
+	// DownloadCancelled is a download stopped by the user
+	DownloadCancelled DownloadState = "cancelled"
+)
+
+// Download describes a file being, or having been, downloaded
+type Download struct {
+	// ID identifies the download within its Downloads
+	ID int
+
+	// URL is where the file was fetched from
+	URL string
+
+	// MIME is the MIME type of the file
+	MIME string
+
+	// Path is where the file is saved. Incomplete files are removed
+	Path string
+
+	// Bytes is the number of bytes received so far
+	Bytes int64
+
+	Started  time.Time
+	Finished time.Time // zero while active
+
+	State DownloadState
+
+	// Err is why a failed download stopped
+	Err error
+}
+
+// Rate returns the average download speed in bytes per second
+func (d Download) Rate() float64 {
+	end := d.Finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	elapsed := end.Sub(d.Started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(d.Bytes) / elapsed
+}
+
+// downloadItem is a download and what is needed to run it
+type downloadItem struct {
+	Download
+	body   io.ReadCloser
+	cancel func()
+	done   chan struct{}
+}
+
+// Downloads saves response bodies to a directory in the background,
+// tracking the progress of each so that several can run at once
+type Downloads struct {
+	mu     sync.Mutex
+	dir    string
+	nextID int
+	items  []*downloadItem
+}
+
+// NewDownloads creates a download manager saving files to dir
+func NewDownloads(dir string) *Downloads {
+	return &Downloads{dir: dir, nextID: 1}
+}
+
+// Dir returns the directory files are saved to
+func (d *Downloads) Dir() string {
+	return d.dir
+}
+
+// Start saves body, the content of rawURL, in the background. The file is
+// named after the URL, with a number added if the name is already taken.
+// cancel, if not nil, is called when the download ends to release the
+// request. Start takes ownership of body
+func (d *Downloads) Start(rawURL, mimeType string, body io.ReadCloser, cancel func()) (Download, error) {
+	if cancel == nil {
+		cancel = func() {}
+	}
+
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		body.Close()
+		cancel()
+		return Download{}, err
+	}
+	f, err := createUnique(d.dir, DownloadName(rawURL, mimeType))
+	if err != nil {
+		body.Close()
+		cancel()
+		return Download{}, err
+	}
+
+	d.mu.Lock()
+	item := &downloadItem{
+		Download: Download{
+			ID:      d.nextID,
+			URL:     rawURL,
+			MIME:    mimeType,
+			Path:    f.Name(),
+			Started: time.Now(),
+			State:   DownloadActive,
+		},
+		body:   body,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	d.nextID++
+	d.items = append(d.items, item)
+	d.mu.Unlock()
+
+	go d.run(item, f)
+	return item.Download, nil
+}
+
+// run copies a download's body to its file and records the outcome
+func (d *Downloads) run(item *downloadItem, f *os.File) {
+	_, err := io.Copy(&progressWriter{w: f, d: d, item: item}, item.body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	item.body.Close()
+	item.cancel()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer close(item.done)
+
+	item.Finished = time.Now()
+	switch {
+	case item.State == DownloadCancelled:
+		os.Remove(item.Path)
+	case err != nil:
+		item.State = DownloadFailed
+		item.Err = err
+		os.Remove(item.Path)
+	default:
+		item.State = DownloadDone
+	}
+}
+
+// progressWriter counts the bytes written to a download's file
+type progressWriter struct {
+	w    io.Writer
+	d    *Downloads
+	item *downloadItem
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+
+	p.d.mu.Lock()
+	p.item.Bytes += int64(n)
+	p.d.mu.Unlock()
+
+	return n, err
+}
+
+// Cancel stops an active download and removes its incomplete file
+func (d *Downloads) Cancel(id int) error {
+	d.mu.Lock()
+	item := d.find(id)
+	if item == nil {
+		d.mu.Unlock()
+		return fmt.Errorf("no download %d", id)
+	}
+	if item.State != DownloadActive {
+		d.mu.Unlock()
+		return fmt.Errorf("download %d has already finished", id)
+	}
+	item.State = DownloadCancelled
+	d.mu.Unlock()
+
+	// Closing the body unblocks the copy, which then cleans up
+	item.body.Close()
+	item.cancel()
+	return nil
+}
+
+// Wait blocks until a download has finished and returns it
+func (d *Downloads) Wait(id int) (Download, error) {
+	d.mu.Lock()
+	item := d.find(id)
+	d.mu.Unlock()
+	if item == nil {
+		return Download{}, fmt.Errorf("no download %d", id)
+	}
+
+	<-item.done
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return item.Download, nil
+}
+
+// List returns the downloads in the order they were started
+func (d *Downloads) List() []Download {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := make([]Download, len(d.items))
+	for i, item := range d.items {
+		list[i] = item.Download
+	}
+	return list
+}
+
+// Active returns the number of downloads still receiving data
+func (d *Downloads) Active() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := 0
+	for _, item := range d.items {
+		if item.State == DownloadActive {
+			n++
+		}
+	}
+	return n
+}
+
+// ClearFinished forgets the downloads that are no longer active. Their
+// files are kept
+func (d *Downloads) ClearFinished() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	active := d.items[:0]
+	for _, item := range d.items {
+		if item.State == DownloadActive {
+			active = append(active, item)
+		}
+	}
+	d.items = active
+}
+
+// find returns the download with an ID (caller must hold lock)
+func (d *Downloads) find(id int) *downloadItem {
+	for _, item := range d.items {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+// DownloadName returns the file name to save a URL's content as: the last
+// element of its path, or its host name, with an extension for the MIME
+// type if it has none. Characters that are unsafe in file names are
+// replaced and leading dots dropped, so a server can't name a hidden file
+func DownloadName(rawURL, mimeType string) string {
+	name, hasExt := "", false
+	if u, err := url.Parse(rawURL); err == nil {
+		name = path.Base(u.Path)
+		hasExt = path.Ext(name) != ""
+		if name == "." || name == "/" {
+			name = u.Hostname()
+		}
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if !unicode.IsPrint(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(name, ". ")
+	if name == "" {
+		name = "download"
+	}
+
+	if !hasExt {
+		name += FileExtension(rawURL, mimeType)
+	}
+	return name
+}
+
+// FileExtension returns the extension for a URL's content: the URL's own,
+// or one registered for the MIME type
+func FileExtension(rawURL, mimeType string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if ext := path.Ext(u.Path); ext != "" {
+			return ext
+		}
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// createUnique creates a new file in dir, adding a number to name if a
+// file of that name already exists
+func createUnique(dir, name string) (*os.File, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 0; ; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !errors.Is(err, os.ErrExist) {
+			return f, err
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloads(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "downloads")
+	d := NewDownloads(dir)
+
+	var ids []int
+	for _, content := range []string{"first", "second"} {
+		dl, err := d.Start("gemini://example.org/files/notes.txt", "text/plain", io.NopCloser(strings.NewReader(content)), nil)
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		ids = append(ids, dl.ID)
+	}
+
+	expected := []struct{ name, content string }{
+		{"notes.txt", "first"},
+		{"notes (1).txt", "second"},
+	}
+	for i, id := range ids {
+		dl, err := d.Wait(id)
+		if err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+		if dl.State != DownloadDone || dl.Bytes != int64(len(expected[i].content)) {
+			t.Errorf("Download %d: expected done with %d bytes, got %s with %d", id, len(expected[i].content), dl.State, dl.Bytes)
+		}
+		if filepath.Base(dl.Path) != expected[i].name {
+			t.Errorf("Download %d: expected file %s, got %s", id, expected[i].name, dl.Path)
+		}
+		data, err := os.ReadFile(dl.Path)
+		if err != nil || string(data) != expected[i].content {
+			t.Errorf("Download %d: expected content %q, got %q (%v)", id, expected[i].content, data, err)
+		}
+	}
+
+	if n := d.Active(); n != 0 {
+		t.Errorf("Expected no active downloads, got %d", n)
+	}
+	d.ClearFinished()
+	if n := len(d.List()); n != 0 {
+		t.Errorf("Expected finished downloads to be cleared, got %d", n)
+	}
+}
+
+func TestDownloadsCancel(t *testing.T) {
+	d := NewDownloads(t.TempDir())
+
+	r, w := io.Pipe()
+	released := false
+	dl, err := d.Start("gemini://example.org/big.tar.gz", "application/gzip", r, func() { released = true })
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	w.Write([]byte("partial"))
+	if n := d.Active(); n != 1 {
+		t.Errorf("Expected 1 active download, got %d", n)
+	}
+
+	if err := d.Cancel(dl.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	dl, _ = d.Wait(dl.ID)
+
+	if dl.State != DownloadCancelled {
+		t.Errorf("Expected cancelled download, got %s", dl.State)
+	}
+	if _, err := os.Stat(dl.Path); !os.IsNotExist(err) {
+		t.Errorf("Expected incomplete file to be removed, got %v", err)
+	}
+	if !released {
+		t.Error("Expected the request to be released")
+	}
+	if err := d.Cancel(dl.ID); err == nil {
+		t.Error("Expected error cancelling a finished download")
+	}
+}
+
+func TestDownloadName(t *testing.T) {
+	tests := []struct {
+		url, mimeType, name string
+	}{
+		{"gemini://example.org/files/a.tar.gz", "application/gzip", "a.tar.gz"},
+		{"gemini://example.org/image", "image/png", "image.png"},
+		{"gemini://example.org/", "application/pdf", "example.org.pdf"},
+		{"gemini://example.org/%2E%2E", "", "download"},
+		{"gemini://example.org/.bashrc", "text/plain", "bashrc"},
+		{"gemini://example.org/...profile.gmi", "text/gemini", "profile.gmi"},
+		{"gemini://example.org/a%0Ab%3Ac.txt", "text/plain", "a_b_c.txt"},
+		{"gemini://example.org/%E2%80%AEfdp.exe", "", "_fdp.exe"},
+	}
+
+	for _, tt := range tests {
+		if got := DownloadName(tt.url, tt.mimeType); got != tt.name {
+			t.Errorf("DownloadName(%q, %q) = %q, expected %q", tt.url, tt.mimeType, got, tt.name)
+		}
+	}
+}
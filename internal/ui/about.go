@@ -0,0 +1,495 @@
+package ui
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/watson-ij/gemini/internal/config"
+	"github.com/watson-ij/gemini/internal/parser"
+	"github.com/watson-ij/gemini/internal/protocol"
+)
+
+// aboutPages lists the internal pages, in the order shown on about:about
+var aboutPages = []struct {
+	name        string
+	description string
+}{
+	{"history", "Pages visited in this session"},
+	{"bookmarks", "Saved pages"},
+	{"certs", "Server certificates trusted on first use"},
+	{"identities", "Client certificates"},
+	{"downloads", "Files saved in this session"},
+	{"cache", "Pages cached for back and forward"},
+	{"config", "Current configuration"},
+}
+
+// visit is an entry of the session history shown on about:history
+type visit struct {
+	url  string
+	time time.Time
+}
+
+// isAboutURL reports whether a URL is an internal about: page
+func isAboutURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "about:")
+}
+
+// aboutURL builds an about: URL from a page name and path segments,
+// escaping each segment so that it may itself contain a URL
+func aboutURL(page string, segments ...string) string {
+	s := "about:" + page
+	for _, segment := range segments {
+		s += "/" + url.PathEscape(segment)
+	}
+	return s
+}
+
+// gemtextText makes text safe to write into a line of a generated page.
+// Text from URLs, servers and certificates could otherwise end the line
+// and start one of its own, such as a link to an about: action
+func gemtextText(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// gemtextLink makes a URL safe to use as the target of a generated link,
+// percent-encoding anything that would end the target or the line
+func gemtextLink(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		rawURL = u.String()
+	}
+	return strings.NewReplacer("\r", "%0D", "\n", "%0A", " ", "%20", "\t", "%09").Replace(rawURL)
+}
+
+// parseAboutURL splits an about: URL into its page name and path segments.
+// Segments after the page name select an action on that page
+func parseAboutURL(u *url.URL) (page string, args []string, err error) {
+	parts := strings.Split(u.Opaque, "/")
+	for _, part := range parts[1:] {
+		arg, err := url.PathUnescape(part)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid URL: %w", err)
+		}
+		args = append(args, arg)
+	}
+	return parts[0], args, nil
+}
+
+// aboutQuery returns the unescaped query of an about: URL and whether one
+// was given; an empty answer to an input prompt is still an answer
+func aboutQuery(u *url.URL) (string, bool) {
+	if u.RawQuery == "" && !u.ForceQuery {
+		return "", false
+	}
+	query, err := url.PathUnescape(u.RawQuery)
+	if err != nil {
+		return u.RawQuery, true
+	}
+	return query, true
+}
+
+// aboutInput asks for input before an action runs, like a status 10 response
+func aboutInput(rawURL, prompt string) *protocol.Response {
+	return &protocol.Response{Status: protocol.StatusInput, Meta: prompt, URL: rawURL}
+}
+
+// aboutRedirect shows another page once an action has run
+func aboutRedirect(rawURL, target string) *protocol.Response {
+	return &protocol.Response{Status: protocol.StatusRedirectTemporary, Meta: target, URL: rawURL}
+}
+
+// loadAbout shows an about: page, or runs an action linked from one.
+// Pages are generated as gemtext and rendered like any other document;
+// actions answer like a server would, by asking for input or redirecting
+func (m *Model) loadAbout(rawURL string) tea.Cmd {
+	return m.openAbout(rawURL, "")
+}
+
+// openAbout loads an about: URL, showing status once a page is displayed
+func (m *Model) openAbout(rawURL, status string) tea.Cmd {
+	u, err := url.Parse(rawURL)
+	var page string
+	var args []string
+	if err == nil {
+		page, args, err = parseAboutURL(u)
+	}
+	if err != nil {
+		m.err = err
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return nil
+	}
+
+	// Actions only run when followed from an about: page, so that links on
+	// remote pages cannot change bookmarks, identities or trusted certificates
+	if len(args) > 0 {
+		if !isAboutURL(m.currentURL) {
+			m.statusMsg = "Error: about: actions can only be followed from about: pages"
+			return nil
+		}
+
+		resp, status, err := m.aboutAction(page, args, u)
+		if err != nil {
+			m.err = err
+			m.statusMsg = fmt.Sprintf("Error: %v", err)
+			return nil
+		}
+
+		if resp.Status.IsInput() {
+			return func() tea.Msg {
+				return inputRequiredMsg{url: rawURL, prompt: resp.Meta}
+			}
+		}
+		if isAboutURL(resp.Meta) {
+			return m.openAbout(resp.Meta, status)
+		}
+		m.statusMsg = status
+		return m.loadURL(resp.Meta)
+	}
+
+	// Pages only take a query when opened from another about: page, so that
+	// a link on a remote page cannot open one on a URL of its choosing.
+	// The exception is the client itself asking for an identity for a
+	// request that was answered with a 6x status
+	if query, ok := aboutQuery(u); ok && !isAboutURL(m.currentURL) {
+		if page != "identities" || query != m.certRequest.url {
+			u.RawQuery, u.ForceQuery = "", false
+			rawURL = u.String()
+		}
+	}
+
+	m.visit(rawURL)
+	m.beginRequest()
+	m.info = pageInfo{url: rawURL}
+
+	content, err := m.aboutPage(page, u)
+	if err != nil {
+		m.finishLoading()
+		m.err = err
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return nil
+	}
+
+	doc, err := parser.Parse(strings.NewReader(content))
+	if err != nil {
+		m.finishLoading()
+		m.err = err
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return nil
+	}
+
+	return func() tea.Msg {
+		return pageLoadedMsg{doc: doc, raw: content, status: status}
+	}
+}
+
+// aboutPage generates the gemtext of an about: page
+func (m *Model) aboutPage(page string, u *url.URL) (string, error) {
+	switch page {
+	case "", "about", "blank":
+		return m.aboutIndexPage(), nil
+	case "history":
+		return m.aboutHistoryPage(), nil
+	case "bookmarks":
+		return m.aboutBookmarksPage(), nil
+	case "certs":
+		filter, _ := aboutQuery(u)
+		return m.aboutCertsPage(filter), nil
+	case "identities":
+		forURL, _ := aboutQuery(u)
+		return m.identitiesPage(forURL), nil
+	case "downloads":
+		return m.aboutDownloadsPage(), nil
+	case "cache":
+		return m.aboutCachePage(), nil
+	case "config":
+		return m.aboutConfigPage(), nil
+	}
+	return "", fmt.Errorf("unknown page: about:%s", page)
+}
+
+// aboutAction runs an action linked from an about: page. It returns the
+// response to handle next and a status message for the user
+func (m *Model) aboutAction(page string, args []string, u *url.URL) (*protocol.Response, string, error) {
+	rawURL := u.String()
+	query, answered := aboutQuery(u)
+
+	switch page {
+	case "history":
+		if args[0] == "clear" {
+			m.visits = nil
+			return aboutRedirect(rawURL, "about:history"), "History cleared", nil
+		}
+
+	case "bookmarks":
+		switch {
+		case args[0] == "add":
+			if !answered || query == "" {
+				return aboutInput(rawURL, "URL to bookmark"), "", nil
+			}
+			if err := m.bookmarks.Add(query, ""); err != nil {
+				return nil, "", err
+			}
+			return aboutRedirect(rawURL, "about:bookmarks"), "Bookmarked " + query, nil
+
+		case args[0] == "remove" && len(args) == 2:
+			if err := m.bookmarks.Remove(args[1]); err != nil {
+				return nil, "", err
+			}
+			return aboutRedirect(rawURL, "about:bookmarks"), "Removed bookmark " + args[1], nil
+		}
+
+	case "certs":
+		if m.client.TOFU == nil {
+			return nil, "", fmt.Errorf("certificate verification is disabled")
+		}
+		switch {
+		case args[0] == "forget" && len(args) == 2:
+			if err := m.client.TOFU.RemoveCertificate(args[1]); err != nil {
+				return nil, "", err
+			}
+			return aboutRedirect(rawURL, "about:certs"), "Forgot certificate for " + args[1], nil
+
+		case args[0] == "repin" && len(args) == 2:
+			if err := m.client.TOFU.RepinCertificate(args[1]); err != nil {
+				return nil, "", err
+			}
+			return aboutRedirect(rawURL, "about:certs"), "The next certificate presented by " + args[1] + " will be trusted", nil
+
+		case args[0] == "search":
+			if !answered {
+				return aboutInput(rawURL, "Search certificates by host, subject or fingerprint"), "", nil
+			}
+			if query == "" {
+				return aboutRedirect(rawURL, "about:certs"), "", nil
+			}
+			return aboutRedirect(rawURL, "about:certs?"+url.PathEscape(query)), "", nil
+
+		case args[0] == "clear":
+			if !answered {
+				return aboutInput(rawURL, "Forget all known certificates? Type yes to confirm"), "", nil
+			}
+			if !strings.EqualFold(query, "yes") {
+				return aboutRedirect(rawURL, "about:certs"), "Cancelled", nil
+			}
+			if err := m.client.TOFU.ClearAll(); err != nil {
+				return nil, "", err
+			}
+			return aboutRedirect(rawURL, "about:certs"), "Forgot all certificates", nil
+		}
+
+	case "identities":
+		return m.identityAction(args, rawURL, query, answered)
+
+	case "downloads":
+		return m.downloadsAction(args, rawURL)
+
+	case "cache":
+		if args[0] == "clear" {
+			if err := m.cache.Clear(); err != nil {
+				return nil, "", err
+			}
+			return aboutRedirect(rawURL, "about:cache"), "Cache cleared", nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("unknown action: %s", rawURL)
+}
+
+// visit records a page in the back/forward history and the session history
+func (m *Model) visit(url string) {
+	if url != m.currentURL {
+		// Trim history after current position
+		m.history = m.history[:m.historyPos+1]
+		m.history = append(m.history, url)
+		m.historyPos = len(m.history) - 1
+	}
+
+	if !isAboutURL(url) {
+		m.visits = append(m.visits, visit{url: url, time: time.Now()})
+	}
+
+	m.currentURL = url
+	m.addressBar.SetValue(url)
+}
+
+// aboutIndexPage lists the about: pages
+func (m *Model) aboutIndexPage() string {
+	var b strings.Builder
+	b.WriteString("# About\n\n")
+	for _, p := range aboutPages {
+		fmt.Fprintf(&b, "=> about:%s %s\n", p.name, p.description)
+	}
+	return b.String()
+}
+
+// aboutHistoryPage lists the pages visited in this session, newest first
+func (m *Model) aboutHistoryPage() string {
+	var b strings.Builder
+	b.WriteString("# History\n\n")
+
+	if len(m.visits) == 0 {
+		b.WriteString("No pages visited yet.\n")
+		return b.String()
+	}
+
+	day := ""
+	for i := len(m.visits) - 1; i >= 0; i-- {
+		v := m.visits[i]
+		if d := v.time.Format("2006-01-02"); d != day {
+			day = d
+			fmt.Fprintf(&b, "\n## %s\n\n", day)
+		}
+		fmt.Fprintf(&b, "=> %s %s %s\n", gemtextLink(v.url), v.time.Format("15:04"), gemtextText(v.url))
+	}
+
+	b.WriteString("\n=> about:history/clear Clear history\n")
+	return b.String()
+}
+
+// aboutBookmarksPage lists the bookmarks with links to remove them
+func (m *Model) aboutBookmarksPage() string {
+	var b strings.Builder
+	b.WriteString("# Bookmarks\n\n")
+
+	bookmarks := m.bookmarks.List()
+	if len(bookmarks) == 0 {
+		b.WriteString("No bookmarks yet. Press Ctrl+D on a page to bookmark it.\n")
+	}
+	for _, bm := range bookmarks {
+		label := bm.Title
+		if label == "" {
+			label = bm.URL
+		}
+		fmt.Fprintf(&b, "=> %s %s\n", gemtextLink(bm.URL), gemtextText(label))
+	}
+
+	b.WriteString("\n## Manage\n\n")
+	b.WriteString("=> about:bookmarks/add Add a bookmark\n")
+	for _, bm := range bookmarks {
+		fmt.Fprintf(&b, "=> %s Remove %s\n", aboutURL("bookmarks", "remove", bm.URL), gemtextText(bm.URL))
+	}
+	return b.String()
+}
+
+// aboutCertsPage lists the server certificates trusted on first use, or
+// those matching a search
+func (m *Model) aboutCertsPage(filter string) string {
+	var b strings.Builder
+	b.WriteString("# Certificates\n\n")
+
+	tofu := m.client.TOFU
+	if tofu == nil {
+		b.WriteString("Certificate verification is disabled.\n")
+		return b.String()
+	}
+
+	hosts := tofu.List()
+	if len(hosts) == 0 {
+		b.WriteString("No certificates have been trusted yet.\n")
+		return b.String()
+	}
+
+	b.WriteString("=> about:certs/search Search by host, subject or fingerprint\n")
+	var shown []protocol.KnownHost
+	for _, host := range hosts {
+		if matchesKnownHost(host, filter) {
+			shown = append(shown, host)
+		}
+	}
+	if filter != "" {
+		fmt.Fprintf(&b, "=> about:certs Show all %d hosts\n\n", len(hosts))
+		fmt.Fprintf(&b, "%d of %d hosts match \"%s\".\n", len(shown), len(hosts), gemtextText(filter))
+	}
+	b.WriteString("\n")
+
+	now := time.Now()
+	for _, host := range shown {
+		fmt.Fprintf(&b, "## %s\n\n", gemtextText(host.Host))
+		fmt.Fprintf(&b, "* Fingerprint: %s\n", host.Fingerprint)
+		if host.KeyFingerprint != "" {
+			fmt.Fprintf(&b, "* Key: %s\n", host.KeyFingerprint)
+		}
+		if host.Subject != "" {
+			fmt.Fprintf(&b, "* Subject: %s\n", gemtextText(host.Subject))
+		}
+		expires := host.NotAfter.Format("2006-01-02")
+		if host.Expired(now) {
+			expires += " (expired)"
+		}
+		fmt.Fprintf(&b, "* Expires: %s\n", expires)
+		fmt.Fprintf(&b, "* First seen: %s\n", host.FirstSeen.Format("2006-01-02"))
+		fmt.Fprintf(&b, "* Last seen: %s\n", host.LastSeen.Format("2006-01-02"))
+		fmt.Fprintf(&b, "* Trust: %s\n", host.Trust)
+		if host.Repin {
+			b.WriteString("* The next certificate presented will be trusted in place of this one\n")
+		} else {
+			fmt.Fprintf(&b, "=> %s Re-pin on next visit\n", aboutURL("certs", "repin", host.Host))
+		}
+		fmt.Fprintf(&b, "=> %s Forget this certificate\n\n", aboutURL("certs", "forget", host.Host))
+	}
+
+	b.WriteString("=> about:certs/clear Forget all certificates\n")
+	return b.String()
+}
+
+// matchesKnownHost reports whether a known host matches a search of
+// about:certs, by host, subject or fingerprint
+func matchesKnownHost(host protocol.KnownHost, filter string) bool {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	if filter == "" {
+		return true
+	}
+	for _, field := range []string{host.Host, host.Subject, host.Fingerprint, host.KeyFingerprint} {
+		if strings.Contains(strings.ToLower(field), filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// aboutConfigPage shows the configuration in effect
+func (m *Model) aboutConfigPage() string {
+	var b strings.Builder
+	b.WriteString("# Configuration\n\n")
+
+	if path, err := config.ConfigPath(); err == nil {
+		fmt.Fprintf(&b, "=> %s %s\n\n", (&url.URL{Scheme: "file", Path: path}).String(), path)
+	}
+
+	cfg := m.config
+	b.WriteString("## Display\n\n")
+	fmt.Fprintf(&b, "* wrap_width = %d\n", cfg.Display.WrapWidth)
+	fmt.Fprintf(&b, "* show_line_numbers = %t\n", cfg.Display.ShowLineNumbers)
+
+	b.WriteString("\n## Proxies\n\n")
+	if len(cfg.Proxies) == 0 {
+		b.WriteString("None configured.\n")
+	}
+	for _, scheme := range sortedKeys(cfg.Proxies) {
+		fmt.Fprintf(&b, "* %s = %s\n", scheme, cfg.Proxies[scheme])
+	}
+
+	// Tokens are secrets, so only the hosts are shown
+	b.WriteString("\n## Titan tokens\n\n")
+	if len(cfg.Titan.Tokens) == 0 {
+		b.WriteString("None configured.\n")
+	}
+	for _, host := range sortedKeys(cfg.Titan.Tokens) {
+		fmt.Fprintf(&b, "* %s\n", host)
+	}
+
+	return b.String()
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,9 +1,12 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
@@ -14,6 +17,7 @@ import (
 	"github.com/watson-ij/gemini/internal/config"
 	"github.com/watson-ij/gemini/internal/parser"
 	"github.com/watson-ij/gemini/internal/protocol"
+	"github.com/watson-ij/gemini/internal/storage"
 )
 
 // AppMode represents the current mode of the application
@@ -31,6 +35,18 @@ const (
 
 	// ModeBookmarks is when the bookmarks sidebar is displayed
 	ModeBookmarks
+
+	// ModeInput is when a server has asked for input (status 10 or 11)
+	ModeInput
+
+	// ModePageInfo is when the page-info panel is displayed
+	ModePageInfo
+
+	// ModeCertPrompt is when a changed server certificate awaits a decision
+	ModeCertPrompt
+
+	// ModeRedirectPrompt is when a redirect to another site awaits confirmation
+	ModeRedirectPrompt
 )
 
 // Model is the main application model
@@ -43,6 +59,10 @@ type Model struct {
 	err        error
 	statusMsg  string
 
+	// warnings describe stored data that could not be loaded; they stay in
+	// the title bar for the whole session
+	warnings []string
+
 	// Components
 	viewport    viewport.Model
 	addressBar  textinput.Model
@@ -53,11 +73,45 @@ type Model struct {
 	currentURL  string
 	document    *parser.Document
 	rawContent  string
+	rawComplete bool // rawContent is the whole page, not just what arrived
 	loading     bool
+	cancel      context.CancelFunc // Cancels the in-flight request
+	stream      *pageStream        // Body currently being received
+	info        pageInfo           // How the current page was fetched
+	slowDown    slowDown           // Countdown after a 44 SLOW DOWN
 	selectedLink int  // Currently selected link index (-1 = none)
 
 	// Protocol
-	client *protocol.Client
+	client     *protocol.Client
+	identities *protocol.IdentityStore
+	prompt     inputPrompt
+	parsers    *parser.Registry
+
+	// Downloads run in the background while browsing continues
+	downloads         *storage.Downloads
+	downloadsWatched  bool // progress ticks are running
+	downloadsReported int  // last finished download reported in the status bar
+
+	// answers are this session's answers to input prompts, by URL
+	answers map[string][]string
+
+	// redirect is a redirect to another site that the user is asked to confirm
+	redirect redirectMsg
+
+	// certRequest is the latest 6x response, shown on about:identities
+	certRequest certRequiredMsg
+
+	// certPrompts delivers questions about changed server certificates,
+	// and certPrompt is the one being shown
+	certPrompts *certPrompter
+	certPrompt  certPrompt
+
+	// Bookmarks and the session history shown on about:history
+	bookmarks *storage.Bookmarks
+	visits    []visit
+
+	// cache holds fetched pages for back/forward navigation
+	cache *storage.Cache
 
 	// Navigation history
 	history  []string  // URLs visited
@@ -141,8 +195,71 @@ func NewModel(startURL string) Model {
 		ti.SetValue(startURL)
 	}
 
+	// warnings describe stored data that could not be loaded
+	var warnings []string
+
+	// Load client identities (or keep them in memory if the store is unavailable)
+	identities := protocol.NewIdentityStore()
+	statusMsg := ""
+	dir, err := config.IdentitiesDir()
+	if err == nil {
+		var store *protocol.IdentityStore
+		if store, err = protocol.LoadIdentityStore(dir); err == nil {
+			identities = store
+		}
+	}
+	if err != nil {
+		statusMsg = fmt.Sprintf("Error: %v", err)
+		warnings = append(warnings, "identities not loaded, new ones are kept for this session only")
+	} else if errs := identities.LoadErrors(); len(errs) > 0 {
+		statusMsg = fmt.Sprintf("Error: %v", errs[0])
+		warnings = append(warnings, fmt.Sprintf("%d stored identities could not be loaded, see about:identities", len(errs)))
+	}
+
+	// Load known server certificates for TOFU verification. If they can't
+	// be loaded, certificates are still checked but only trusted for this
+	// session, and the file is left alone rather than overwritten
+	tofu := protocol.NewMemoryTOFUVerifier()
+	path, err := config.KnownHostsPath()
+	if err == nil {
+		var loaded *protocol.TOFUVerifier
+		if loaded, err = protocol.NewTOFUVerifier(path); err == nil {
+			tofu = loaded
+		}
+	}
+	if err != nil {
+		statusMsg = fmt.Sprintf("Error: %v", err)
+		warnings = append(warnings, "known hosts not loaded, certificates are trusted for this session only")
+	}
+
+	// Load bookmarks (or keep them in memory if the file is unavailable)
+	bookmarks := storage.NewBookmarks()
+	if path, err := config.BookmarksPath(); err == nil {
+		if store, err := storage.LoadBookmarks(path); err == nil {
+			bookmarks = store
+		} else {
+			statusMsg = fmt.Sprintf("Error: %v", err)
+		}
+	}
+
+	// Create the page cache, on disk too if configured
+	cacheOpts := storage.CacheOptions{
+		MaxEntries: cfg.Cache.MemoryEntries,
+		MaxAge:     cfg.Cache.MaxAge.Duration,
+	}
+	if cfg.Cache.Disk {
+		if dir, err := config.CacheDir(); err == nil {
+			cacheOpts.Dir = dir
+			cacheOpts.MaxDiskBytes = int64(cfg.Cache.DiskSizeMB) << 20
+		}
+	}
+
 	// Create Gemini client
 	client := protocol.NewClient()
+	client.TOFU = tofu
+	client.Identities = identities
+	client.Proxies = cfg.Proxies
+	client.OnRedirect = approveRedirect
 
 	// Create viewport
 	vp := viewport.New(80, 20)
@@ -160,12 +277,20 @@ func NewModel(startURL string) Model {
 		help:         help.New(),
 		keys:         DefaultKeyMap(),
 		client:       client,
+		certPrompts:  newCertPrompter(tofu),
+		parsers:      newParsers(),
+		downloads:    storage.NewDownloads(downloadsDir(cfg)),
+		identities:   identities,
+		bookmarks:    bookmarks,
+		cache:        storage.NewCache(cacheOpts),
 		currentURL:   startURL,
 		selectedLink: -1,
 		history:      []string{},
 		historyPos:   -1,
 		config:       cfg,
 		styles:       DefaultStyles(),
+		statusMsg:    statusMsg,
+		warnings:     warnings,
 	}
 
 	return m
@@ -175,9 +300,9 @@ func NewModel(startURL string) Model {
 func (m Model) Init() tea.Cmd {
 	// If we have a start URL, load it
 	if m.currentURL != "" {
-		return m.loadURL(m.currentURL)
+		return tea.Batch(m.certPrompts.wait(), m.loadURL(m.currentURL))
 	}
-	return nil
+	return m.certPrompts.wait()
 }
 
 // Update handles messages and updates the model
@@ -216,15 +341,81 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case pageLoadedMsg:
-		m.loading = false
+		m.finishLoading()
 		m.document = msg.doc
 		m.rawContent = msg.raw
+		m.rawComplete = true
 		m.selectedLink = -1
-		m.statusMsg = fmt.Sprintf("Loaded %d lines, %d links", msg.doc.LineCount(), msg.doc.LinkCount())
+		m.statusMsg = msg.status
+		if m.statusMsg == "" {
+			m.statusMsg = fmt.Sprintf("Loaded %d lines, %d links", msg.doc.LineCount(), msg.doc.LinkCount())
+		}
+		m.viewport.GotoTop()
 		m.renderDocument()
 
+	case streamStartMsg:
+		return m, m.startStream(msg.stream)
+
+	case streamChunkMsg:
+		return m, m.appendStreamChunk(msg)
+
+	case streamRenderMsg:
+		if msg.stream == m.stream && msg.stream.renderPending {
+			m.refreshStream(msg.stream)
+		}
+
+	case pageEditedMsg:
+		return m, m.uploadPage(msg)
+
+	case uploadedMsg:
+		return m, m.finishUpload(msg)
+
+	case inputRequiredMsg:
+		m.finishLoading()
+		return m, m.openInputPrompt(msg)
+
+	case inputEditedMsg:
+		return m, m.finishInputEdit(msg)
+
+	case redirectMsg:
+		return m, m.showRedirect(msg)
+
+	case downloadStartedMsg:
+		return m, m.startDownload(msg)
+
+	case downloadTickMsg:
+		return m, m.updateDownloads()
+
+	case handledMsg:
+		m.finishLoading()
+		m.leaveURL(msg.url)
+		m.statusMsg = msg.status
+
+	case slowDownMsg:
+		return m, m.startSlowDown(msg)
+
+	case slowDownTickMsg:
+		return m, m.updateSlowDown(msg)
+
+	case certPromptMsg:
+		m.openCertPrompt(msg)
+
+	case certRequiredMsg:
+		// Identities are chosen on about:identities, which shows this request
+		m.finishLoading()
+		m.certRequest = msg
+		target, err := inputURL("about:identities", msg.url)
+		if err != nil {
+			return m, nil
+		}
+		return m, m.loadURL(target)
+
 	case errorMsg:
-		m.loading = false
+		// A cancelled request was stopped or superseded by the user
+		if errors.Is(msg.err, context.Canceled) {
+			return m, nil
+		}
+		m.finishLoading()
 		m.err = msg.err
 		m.statusMsg = fmt.Sprintf("Error: %v", msg.err)
 
@@ -239,10 +430,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.mode = ModeBrowse
 			}
 			return m, nil
+
+		case ModeInput:
+			return m.updateInputPrompt(msg)
+
+		case ModePageInfo:
+			return m.updatePageInfo(msg)
+
+		case ModeCertPrompt:
+			return m.updateCertPrompt(msg)
+
+		case ModeRedirectPrompt:
+			return m.updateRedirectPrompt(msg)
 		}
 
 		// Global keys (browse mode)
 		switch {
+		case m.loading && key.Matches(msg, m.keys.Stop):
+			m.stopLoading()
+			return m, nil
+
+		case m.slowDown.active() && key.Matches(msg, m.keys.Stop):
+			m.cancelSlowDown()
+			return m, nil
+
 		case key.Matches(msg, m.keys.Quit):
 			return m, tea.Quit
 
@@ -250,6 +461,29 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.mode = ModeHelp
 			return m, nil
 
+		case key.Matches(msg, m.keys.EditPage):
+			return m, m.editPage()
+
+		case key.Matches(msg, m.keys.Identities):
+			return m, m.loadURL("about:identities")
+
+		case key.Matches(msg, m.keys.KnownHosts):
+			return m, m.loadURL("about:certs")
+
+		case key.Matches(msg, m.keys.ToggleSidebar):
+			return m, m.loadURL("about:bookmarks")
+
+		case key.Matches(msg, m.keys.ShowHistory):
+			return m, m.loadURL("about:history")
+
+		case key.Matches(msg, m.keys.PageInfo):
+			m.openPageInfo()
+			return m, nil
+
+		case key.Matches(msg, m.keys.BookmarkPage):
+			m.toggleBookmark()
+			return m, nil
+
 		case key.Matches(msg, m.keys.FocusAddress):
 			m.mode = ModeAddressBar
 			m.addressBar.Focus()
@@ -263,6 +497,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if !strings.HasPrefix(url, "gemini://") {
 					url = m.resolveURL(url)
 				}
+				// Prompt links ask for input, which is sent as the request body
+				if link.Type == parser.LineTypePromptLink {
+					return m, m.openInputPrompt(inputRequiredMsg{url: url, prompt: link.Link.Display})
+				}
 				return m, m.loadURL(url)
 			}
 
@@ -298,7 +536,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				url := m.history[m.historyPos]
 				m.currentURL = url
 				m.addressBar.SetValue(url)
-				return m, m.loadURL(url)
+				return m, m.loadCached(url)
 			}
 
 		case key.Matches(msg, m.keys.Forward):
@@ -307,7 +545,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				url := m.history[m.historyPos]
 				m.currentURL = url
 				m.addressBar.SetValue(url)
-				return m, m.loadURL(url)
+				return m, m.loadCached(url)
 			}
 
 		case key.Matches(msg, m.keys.Home):
@@ -362,6 +600,12 @@ func (m Model) View() string {
 	switch m.mode {
 	case ModeHelp:
 		return m.helpView()
+	case ModeInput:
+		return m.inputView()
+	case ModePageInfo:
+		return m.pageInfoView()
+	case ModeCertPrompt:
+		return m.certPromptView()
 	default:
 		return m.browseView()
 	}
@@ -371,6 +615,10 @@ func (m Model) View() string {
 func (m Model) browseView() string {
 	// Title bar
 	title := m.styles.TitleBar.Render("📡 Gemini Browser")
+	if len(m.warnings) > 0 {
+		warning := m.styles.StatusBarError.Render("⚠ " + strings.Join(m.warnings, "; "))
+		title = lipgloss.JoinHorizontal(lipgloss.Top, title, warning)
+	}
 
 	// Address bar
 	addressStyle := m.styles.AddressBar
@@ -386,13 +634,19 @@ func (m Model) browseView() string {
 	statusStyle := m.styles.StatusBar
 	if m.err != nil {
 		statusStyle = m.styles.StatusBarError
-	} else if m.loading {
+	} else if m.loading || m.slowDown.active() {
 		statusStyle = m.styles.StatusBarInfo
 	}
 
 	statusLeft := m.statusMsg
-	if m.loading {
-		statusLeft = "Loading..."
+	if m.stream != nil {
+		statusLeft = fmt.Sprintf("Receiving... %d lines (esc to stop)", m.document.LineCount())
+	} else if m.loading {
+		statusLeft = "Loading... (esc to stop)"
+	} else if m.slowDown.active() {
+		statusLeft = m.slowDownStatus()
+	} else if m.mode == ModeRedirectPrompt {
+		statusLeft = fmt.Sprintf("Follow redirect to %s? (y/n)", m.redirect.resp.Meta)
 	}
 
 	linkCount := 0
@@ -400,7 +654,7 @@ func (m Model) browseView() string {
 		linkCount = m.document.LinkCount()
 	}
 
-	statusRight := fmt.Sprintf("Link %d/%d | %d%% | ? for help",
+	statusRight := m.downloadsStatus() + fmt.Sprintf("Link %d/%d | %d%% | ? for help",
 		m.selectedLink+1,
 		linkCount,
 		int(float64(m.viewport.YOffset)/float64(max(1, len(strings.Split(m.viewport.View(), "\n"))-1))*100))
@@ -448,13 +702,18 @@ Links:
 
 URL Navigation:
   Ctrl+L         Focus address bar
-  Ctrl+R         Reload current page
+  Ctrl+R         Reload current page (bypassing the cache)
+  Esc / Ctrl+C   Stop loading
   Alt+← / p      Go back
   Alt+→ / n      Go forward
 
 Other:
-  Ctrl+B         Toggle bookmarks (TODO)
-  Ctrl+D         Bookmark page (TODO)
+  e              Edit page in $EDITOR and upload it with Titan
+  I              Manage client identities (about:identities)
+  Ctrl+B         Show bookmarks (about:bookmarks)
+  Ctrl+D         Bookmark or unbookmark page
+  i              Page info (status, MIME type, timing, certificate)
+  H              Show history (about:history)
   Ctrl+F         Find in page (TODO)
   ?              Show this help
   Ctrl+Q         Quit
@@ -469,6 +728,18 @@ Configuration:
   wrap_width = 100        # Set to 0 to use terminal width
   show_line_numbers = false
 
+  [proxies]
+  https = "proxy.example.org"  # Fetch https:// links through a Gemini proxy
+
+  [titan.tokens]
+  "example.org" = "secret"     # Token sent with Titan uploads to a host
+
+  [cache]
+  memory_entries = 100    # Pages kept for back/forward (about:cache)
+  disk = false            # Also keep pages on disk
+  disk_size_mb = 50
+  max_age = "24h"
+
 Press ? or ESC to close this help screen.
 `, configPath)
 
@@ -486,6 +757,20 @@ func (m *Model) renderDocument() {
 		return
 	}
 
+	content, links := m.renderer().RenderFrom(m.document, 0, 0)
+	m.viewport.SetContent(content)
+
+	// Lines still to arrive on a stream are rendered onto the end of this
+	if m.stream != nil {
+		m.stream.rendered.Reset()
+		m.stream.rendered.WriteString(content)
+		m.stream.renderedLines, m.stream.renderedLinks = len(m.document.Lines), links
+		m.stream.lastRender = time.Now()
+	}
+}
+
+// renderer returns a renderer for the current display settings
+func (m *Model) renderer() *parser.Renderer {
 	// Determine the wrap width to use
 	// If config.WrapWidth is 0, use the full viewport width
 	// Otherwise, use the minimum of config.WrapWidth and viewport width
@@ -496,58 +781,115 @@ func (m *Model) renderDocument() {
 		}
 	}
 
-	renderer := parser.NewRenderer(&parser.RenderOptions{
+	return parser.NewRenderer(&parser.RenderOptions{
 		Width:           wrapWidth,
 		NumberLinks:     true,
 		HighlightedLink: m.selectedLink,
 		ColorScheme:     parser.DefaultColorScheme(),
 		ShowLineNumbers: m.config.Display.ShowLineNumbers,
 	})
-
-	content := renderer.Render(m.document)
-	m.viewport.SetContent(content)
 }
 
 // loadURL loads a URL and returns a command
 func (m *Model) loadURL(url string) tea.Cmd {
-	// Add to history
-	if url != m.currentURL {
-		// Trim history after current position
-		m.history = m.history[:m.historyPos+1]
-		m.history = append(m.history, url)
-		m.historyPos = len(m.history) - 1
+	if isAboutURL(url) {
+		return m.loadAbout(url)
 	}
 
-	m.currentURL = url
-	m.addressBar.SetValue(url)
-	m.loading = true
-	m.err = nil
+	m.visit(url)
+	ctx := m.beginRequest()
 
+	client, parsers := m.client, m.parsers
+	handlers, downloads := m.config.Handlers, m.downloads
+	detach := m.detachable()
 	return func() tea.Msg {
-		resp, err := m.client.Get(url)
+		started := time.Now()
+		resp, err := client.GetContext(ctx, url)
+		var slow *protocol.SlowDownError
+		if errors.As(err, &slow) {
+			return slowDownMsg{url: url, host: slow.Host, until: slow.Until}
+		}
 		if err != nil {
 			return errorMsg{err: err}
 		}
-		defer resp.Close()
 
-		if !resp.Status.IsSuccess() {
-			return errorMsg{err: fmt.Errorf("status %d: %s", resp.Status, resp.Meta)}
+		// The client has started the host's back-off window
+		if resp.Status == protocol.StatusSlowDown {
+			return slowDownMsg{url: url, host: client.BackoffHost(resp.URL), until: time.Now().Add(protocol.ParseSlowDown(resp.Meta))}
 		}
 
-		body, err := resp.ReadBody()
-		if err != nil {
-			return errorMsg{err: err}
+		// Answers and certificates are for the URL the redirects led to
+		if resp.Status.IsInput() {
+			return inputRequiredMsg{
+				url:       resp.URL,
+				prompt:    resp.Meta,
+				sensitive: resp.Status == protocol.StatusSensitiveInput,
+			}
 		}
 
-		doc, err := parser.ParseString(string(body))
-		if err != nil {
-			return errorMsg{err: err}
+		if resp.Status.IsClientCertificate() {
+			return certRequiredMsg{url: resp.URL, status: resp.Status, meta: resp.Meta}
+		}
+
+		if resp.Status.IsRedirect() {
+			return redirectMsg{resp: resp}
 		}
 
-		return pageLoadedMsg{
-			doc: doc,
-			raw: string(body),
+		if !resp.Status.IsSuccess() {
+			return errorMsg{err: resp.Err()}
+		}
+
+		// Types that are not shown as pages are opened elsewhere or saved
+		lp, ok := parsers.Lookup(contentType(resp))
+		if !ok {
+			msg, err := handleContent(resp, handlers, downloads, detach)
+			if err != nil {
+				return errorMsg{err: err}
+			}
+			return msg
 		}
+
+		// The body is parsed and rendered as it arrives
+		stream := newPageStream(ctx, resp, lp)
+		stream.started, stream.header = started, time.Since(started)
+		return streamStartMsg{stream: stream}
+	}
+}
+
+// beginRequest cancels any request still in flight and returns the context
+// for a new one; only one page loads at a time
+func (m *Model) beginRequest() context.Context {
+	m.dropStream()
+	if m.cancel != nil {
+		m.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.loading = true
+	m.slowDown = slowDown{}
+	m.err = nil
+	return ctx
+}
+
+// stopLoading cancels the in-flight request, keeping whatever part of the
+// page has already been received
+func (m *Model) stopLoading() {
+	m.statusMsg = "Stopped"
+	if m.stream != nil {
+		m.statusMsg = fmt.Sprintf("Stopped after %d lines", m.document.LineCount())
+		m.refreshStream(m.stream)
+	}
+	m.dropStream()
+	m.finishLoading()
+}
+
+// finishLoading clears the loading state and releases the request context
+func (m *Model) finishLoading() {
+	m.loading = false
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
 	}
 }
 
@@ -576,6 +918,9 @@ func (m *Model) resolveURL(relativeURL string) string {
 type pageLoadedMsg struct {
 	doc *parser.Document
 	raw string
+
+	// status replaces the usual "Loaded" message if set
+	status string
 }
 
 type errorMsg struct {
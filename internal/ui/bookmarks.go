@@ -0,0 +1,36 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/watson-ij/gemini/internal/parser"
+)
+
+// toggleBookmark bookmarks the current page, or removes its bookmark
+func (m *Model) toggleBookmark() {
+	if m.currentURL == "" || isAboutURL(m.currentURL) {
+		return
+	}
+
+	var err error
+	if m.bookmarks.Contains(m.currentURL) {
+		err = m.bookmarks.Remove(m.currentURL)
+		m.statusMsg = "Removed bookmark"
+	} else {
+		err = m.bookmarks.Add(m.currentURL, documentTitle(m.document))
+		m.statusMsg = "Bookmarked (Ctrl+B to show bookmarks)"
+	}
+
+	if err != nil {
+		m.err = err
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+	}
+}
+
+// documentTitle returns the text of a document's first heading, if any
+func documentTitle(doc *parser.Document) string {
+	if doc == nil || doc.HeadingCount() == 0 {
+		return ""
+	}
+	return doc.Headings[0].Text
+}
@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/watson-ij/gemini/internal/parser"
+	"github.com/watson-ij/gemini/internal/protocol"
+	"github.com/watson-ij/gemini/internal/storage"
+)
+
+// cacheable reports whether pages from a URL are kept in the page cache.
+// Internal and local pages are cheap to regenerate and may change at any time
+func cacheable(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme != "about" && u.Scheme != "file"
+}
+
+// cachePage stores a fully received page
+func (m *Model) cachePage(rawURL string, resp *protocol.Response, body string) {
+	if !cacheable(rawURL) {
+		return
+	}
+
+	m.cache.Put(&storage.CacheEntry{
+		URL:  rawURL,
+		Meta: utf8Meta(resp),
+		Body: []byte(body),
+
+		// Pages fetched with a client certificate are not written to disk
+		Private: m.identities.ForURL(rawURL) != nil,
+	})
+}
+
+// utf8Meta returns the meta of a text response with its charset set to
+// UTF-8, as the body has been decoded by the time it is cached
+func utf8Meta(resp *protocol.Response) string {
+	params := resp.Params()
+	if params["charset"] == "" {
+		return resp.Meta
+	}
+	params["charset"] = protocol.DefaultCharset
+	if meta := mime.FormatMediaType(resp.MIMEType(), params); meta != "" {
+		return meta
+	}
+	return resp.Meta
+}
+
+// loadCached shows a page from the cache if it is there, and fetches it
+// otherwise. Back and forward use it so that history navigation is instant;
+// reloading always fetches
+func (m *Model) loadCached(rawURL string) tea.Cmd {
+	entry, ok := m.cache.Get(rawURL)
+	if !ok || !cacheable(rawURL) {
+		return m.loadURL(rawURL)
+	}
+
+	resp := &protocol.Response{Status: protocol.StatusSuccess, Meta: entry.Meta}
+	lp, ok := m.parsers.Lookup(contentType(resp))
+	if !ok {
+		return m.loadURL(rawURL)
+	}
+	doc, err := parser.ParseLines(bytes.NewReader(entry.Body), lp)
+	if err != nil {
+		return m.loadURL(rawURL)
+	}
+	doc.Lang = resp.Lang()
+
+	// Back and forward have already moved through the history, and a page
+	// restored from the cache is not a new visit for about:history
+	m.beginRequest()
+	m.info = pageInfo{url: rawURL, resp: resp, cached: entry.Fetched}
+
+	status := fmt.Sprintf("Loaded %d lines from cache (fetched %s ago, ctrl+r to reload)",
+		doc.LineCount(), time.Since(entry.Fetched).Round(time.Second))
+	raw := string(entry.Body)
+	return func() tea.Msg {
+		return pageLoadedMsg{doc: doc, raw: raw, status: status}
+	}
+}
+
+// aboutCachePage describes the page cache
+func (m *Model) aboutCachePage() string {
+	stats := m.cache.Stats()
+
+	var b bytes.Buffer
+	b.WriteString("# Page Cache\n\n")
+	b.WriteString("Pages are cached so that going back and forward is instant. Reloading a page always fetches it again.\n\n")
+	fmt.Fprintf(&b, "* In memory: %d pages, %s\n", stats.Entries, formatBytes(stats.Bytes))
+	if m.config.Cache.Disk {
+		fmt.Fprintf(&b, "* On disk: %d pages, %s\n", stats.DiskEntries, formatBytes(stats.DiskBytes))
+	} else {
+		b.WriteString("* On disk: disabled\n")
+	}
+	fmt.Fprintf(&b, "* Pages expire after %s\n", m.config.Cache.MaxAge.Duration)
+	b.WriteString("\n=> about:cache/clear Clear the cache\n")
+	return b.String()
+}
+
+// formatBytes formats a size in bytes for display
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
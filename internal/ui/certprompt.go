@@ -0,0 +1,203 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/watson-ij/gemini/internal/protocol"
+)
+
+// certPromptMsg asks the user whether to trust a server certificate that
+// differs from the known one. It is sent by a request waiting in the TOFU
+// verifier, which resumes when an answer is sent on reply
+type certPromptMsg struct {
+	host  string
+	known *protocol.CertificateInfo
+	cert  *protocol.CertificateInfo
+	reply chan<- certAnswer
+}
+
+// certAnswer is the user's decision on a changed certificate
+type certAnswer struct {
+	accept bool
+	trust  protocol.TrustLevel
+}
+
+// certPrompter bridges the TOFU verifier's callbacks, which run on request
+// goroutines, to the update loop. One prompt is shown at a time; other
+// requests wait their turn
+type certPrompter struct {
+	prompts chan certPromptMsg
+}
+
+// newCertPrompter creates a prompter and installs it on the verifier
+func newCertPrompter(tofu *protocol.TOFUVerifier) *certPrompter {
+	p := &certPrompter{prompts: make(chan certPromptMsg)}
+	if tofu != nil {
+		tofu.OnCertificateChange = p.certificateChanged
+	}
+	return p
+}
+
+// certificateChanged asks the user about a changed certificate and waits
+// for the answer
+func (p *certPrompter) certificateChanged(host string, known, cert *protocol.CertificateInfo) (bool, protocol.TrustLevel) {
+	reply := make(chan certAnswer, 1)
+	p.prompts <- certPromptMsg{host: host, known: known, cert: cert, reply: reply}
+	answer := <-reply
+	return answer.accept, answer.trust
+}
+
+// wait returns a command that delivers the next prompt
+func (p *certPrompter) wait() tea.Cmd {
+	return func() tea.Msg {
+		return <-p.prompts
+	}
+}
+
+// certPrompt is the certificate question being shown
+type certPrompt struct {
+	certPromptMsg
+
+	// returnMode is the mode to go back to once answered
+	returnMode AppMode
+}
+
+// expiredRenewal reports whether the known certificate has expired and the
+// presented one has not, which is how servers usually renew
+func (p certPrompt) expiredRenewal() bool {
+	now := time.Now()
+	return p.known.Expired(now) && !p.cert.Expired(now)
+}
+
+// openCertPrompt shows a changed certificate and asks what to do
+func (m *Model) openCertPrompt(msg certPromptMsg) {
+	m.certPrompt = certPrompt{certPromptMsg: msg, returnMode: m.mode}
+	m.mode = ModeCertPrompt
+}
+
+// updateCertPrompt handles keys while a certificate question is shown
+func (m Model) updateCertPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var answer certAnswer
+	switch msg.String() {
+	case "r", "esc":
+		answer = certAnswer{accept: false}
+	case "o":
+		answer = certAnswer{accept: true, trust: protocol.TrustOnce}
+	case "s":
+		answer = certAnswer{accept: true, trust: protocol.TrustSession}
+	case "a":
+		answer = certAnswer{accept: true, trust: protocol.TrustPermanent}
+	case "enter":
+		// Renewals of expired certificates are accepted with a single key
+		if !m.certPrompt.expiredRenewal() {
+			return m, nil
+		}
+		answer = certAnswer{accept: true, trust: protocol.TrustPermanent}
+	default:
+		return m, nil
+	}
+
+	prompt := m.certPrompt
+	prompt.reply <- answer
+	m.certPrompt = certPrompt{}
+	m.mode = prompt.returnMode
+
+	switch answer.trust {
+	case protocol.TrustOnce:
+		m.statusMsg = fmt.Sprintf("Accepted the new certificate for %s once", prompt.host)
+	case protocol.TrustSession:
+		m.statusMsg = fmt.Sprintf("Accepted the new certificate for %s for this session", prompt.host)
+	case protocol.TrustPermanent:
+		m.statusMsg = fmt.Sprintf("Trusted the new certificate for %s", prompt.host)
+	default:
+		m.statusMsg = fmt.Sprintf("Rejected the new certificate for %s", prompt.host)
+	}
+	return m, m.certPrompts.wait()
+}
+
+// certPromptView renders the certificate question
+func (m Model) certPromptView() string {
+	if m.certPrompt.expiredRenewal() {
+		return m.renewalPromptView()
+	}
+
+	title := m.styles.TitleBar.Render("Certificate Changed")
+
+	var b strings.Builder
+	row := func(label, format string, args ...any) {
+		fmt.Fprintf(&b, "  %-14s %s\n", label+":", fmt.Sprintf(format, args...))
+	}
+
+	prompt := m.certPrompt
+	known, cert := prompt.known, prompt.cert
+	fmt.Fprintf(&b, "The certificate presented by %s is not the one trusted before.\n", prompt.host)
+	switch {
+	case known.KeyFingerprint == "":
+		b.WriteString("The known certificate was pinned before public keys were recorded.\n")
+	case known.KeyFingerprint != cert.KeyFingerprint:
+		b.WriteString("It uses a different public key.\n")
+	}
+	if !known.Expired(time.Now()) {
+		b.WriteString("The known certificate has not expired, so this change is unexpected.\n")
+	}
+	b.WriteString("This can be a routine change on the server, or someone intercepting the connection.\n")
+
+	b.WriteString("\nKnown certificate\n")
+	writeCertInfo(row, known)
+	row("First seen", "%s", known.FirstSeen.Format("2006-01-02"))
+	row("Last seen", "%s", known.LastSeen.Format("2006-01-02"))
+	row("Trust", "%s", known.Trust)
+
+	b.WriteString("\nPresented certificate\n")
+	writeCertInfo(row, cert)
+
+	b.WriteString("\nr/esc: reject | o: accept once | s: accept for this session | a: always accept")
+
+	content := lipgloss.NewStyle().
+		Padding(1, 2).
+		Render(b.String())
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, content)
+}
+
+// writeCertInfo adds the rows describing a certificate
+func writeCertInfo(row func(label, format string, args ...any), info *protocol.CertificateInfo) {
+	if info.Subject != "" {
+		row("Subject", "%s", info.Subject)
+	}
+	row("Fingerprint", "%s", info.Fingerprint)
+	if info.KeyFingerprint != "" {
+		row("Key", "%s", info.KeyFingerprint)
+	}
+	expires := info.NotAfter.Format("2006-01-02")
+	if info.Expired(time.Now()) {
+		expires += " (expired)"
+	}
+	row("Expires", "%s", expires)
+}
+
+// renewalPromptView renders the lighter question shown when an expired
+// certificate has been replaced by a valid one
+func (m Model) renewalPromptView() string {
+	title := m.styles.TitleBar.Render("Expired Certificate Replaced")
+
+	prompt := m.certPrompt
+	var b strings.Builder
+	fmt.Fprintf(&b, "The certificate trusted for %s expired on %s.\n", prompt.host, prompt.known.NotAfter.Format("2006-01-02"))
+	fmt.Fprintf(&b, "The server now presents a new one, valid until %s.\n", prompt.cert.NotAfter.Format("2006-01-02"))
+	b.WriteString("Servers must replace expired certificates, so this is usually a routine renewal.\n\n")
+	fmt.Fprintf(&b, "  %-14s %s\n", "Known:", prompt.known.Fingerprint)
+	fmt.Fprintf(&b, "  %-14s %s\n", "Presented:", prompt.cert.Fingerprint)
+
+	b.WriteString("\nenter/a: trust the new certificate | r/esc: reject | o: accept once | s: accept for this session")
+
+	content := lipgloss.NewStyle().
+		Padding(1, 2).
+		Render(b.String())
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, content)
+}
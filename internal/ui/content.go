@@ -0,0 +1,134 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/watson-ij/gemini/internal/parser"
+	"github.com/watson-ij/gemini/internal/protocol"
+	"github.com/watson-ij/gemini/internal/storage"
+)
+
+// handledMsg is sent when a response that is not shown as a page has been
+// saved or passed to an external program
+type handledMsg struct {
+	url    string
+	status string
+}
+
+// newParsers creates the registry of MIME types shown as pages
+func newParsers() *parser.Registry {
+	r := parser.NewRegistry()
+	r.Register(protocol.GopherMenuMIME, func() parser.LineParser { return parser.NewGopherMenuParser() })
+	return r
+}
+
+// contentType returns the MIME type of a success response; an empty meta
+// means gemtext
+func contentType(resp *protocol.Response) string {
+	if mimeType := resp.MIMEType(); mimeType != "" {
+		return mimeType
+	}
+	return "text/gemini"
+}
+
+// handlerFor returns the program configured for a MIME type. Exact types
+// are preferred over patterns, and longer patterns over shorter ones
+func handlerFor(handlers map[string]string, mimeType string) string {
+	patterns := make([]string, 0, len(handlers))
+	for pattern := range handlers {
+		if parser.MatchMIME(pattern, mimeType) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return ""
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		wi, wj := strings.Contains(patterns[i], "*"), strings.Contains(patterns[j], "*")
+		if wi != wj {
+			return !wi
+		}
+		return len(patterns[i]) > len(patterns[j])
+	})
+	return handlers[patterns[0]]
+}
+
+// handleContent deals with a response that is not shown as a page: it is
+// opened with the program configured for its type or, failing that,
+// downloaded in the background. It runs outside the update loop; detach
+// takes the request over from the page so that browsing on does not
+// cancel a download
+func handleContent(resp *protocol.Response, handlers map[string]string, downloads *storage.Downloads, detach func() context.CancelFunc) (tea.Msg, error) {
+	mimeType := contentType(resp)
+
+	if program := handlerFor(handlers, mimeType); program != "" {
+		defer resp.Body.Close()
+		f, err := os.CreateTemp("", "gemini-*"+storage.FileExtension(resp.URL, mimeType))
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBody(f, resp.Body); err != nil {
+			os.Remove(f.Name())
+			return nil, err
+		}
+		if err := openWith(program, f.Name()); err != nil {
+			os.Remove(f.Name())
+			return nil, err
+		}
+		return handledMsg{url: resp.URL, status: fmt.Sprintf("Opened %s with %s", mimeType, program)}, nil
+	}
+
+	dl, err := downloads.Start(resp.URL, mimeType, resp.Body, detach())
+	if err != nil {
+		return nil, err
+	}
+	return downloadStartedMsg{download: dl}, nil
+}
+
+// writeBody copies a body to a file and closes it
+func writeBody(f *os.File, body io.Reader) error {
+	_, err := io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// openWith starts a program on a temporary file in the background, and
+// removes the file once the program exits. The program may include
+// arguments (e.g. "mpv --no-video"); the file is added last
+func openWith(program, file string) error {
+	args := strings.Fields(program)
+	if len(args) == 0 {
+		return fmt.Errorf("no program configured")
+	}
+	cmd := exec.Command(args[0], append(args[1:], file)...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", args[0], err)
+	}
+	go func() {
+		cmd.Wait()
+		os.Remove(file)
+	}()
+	return nil
+}
+
+// leaveURL returns to the previous page after a request for rawURL that
+// was not shown, such as a download
+func (m *Model) leaveURL(rawURL string) {
+	if m.currentURL != rawURL || m.historyPos <= 0 || m.history[m.historyPos] != rawURL {
+		return
+	}
+	m.history = m.history[:m.historyPos]
+	m.historyPos--
+	m.currentURL = m.history[m.historyPos]
+	m.addressBar.SetValue(m.currentURL)
+}
@@ -0,0 +1,221 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/watson-ij/gemini/internal/config"
+	"github.com/watson-ij/gemini/internal/parser"
+	"github.com/watson-ij/gemini/internal/protocol"
+	"github.com/watson-ij/gemini/internal/storage"
+)
+
+// downloadTickInterval is how often download progress is refreshed
+const downloadTickInterval = 500 * time.Millisecond
+
+// downloadStartedMsg is sent when a response is being downloaded
+type downloadStartedMsg struct {
+	download storage.Download
+}
+
+// downloadTickMsg refreshes download progress while downloads are active
+type downloadTickMsg struct{}
+
+// detachable lets the in-flight request be taken over by a download. Until
+// the returned function is called, stopping the page load cancels the
+// request as usual; afterwards it is left to the download, and the function
+// returns what cancels it
+func (m *Model) detachable() func() context.CancelFunc {
+	cancel := m.cancel
+	var detached atomic.Bool
+	m.cancel = func() {
+		if !detached.Load() {
+			cancel()
+		}
+	}
+	return func() context.CancelFunc {
+		detached.Store(true)
+		return cancel
+	}
+}
+
+// startDownload reports a new download and starts following its progress
+func (m *Model) startDownload(msg downloadStartedMsg) tea.Cmd {
+	m.finishLoading()
+	m.leaveURL(msg.download.URL)
+	m.statusMsg = fmt.Sprintf("Downloading %s (about:downloads)", filepath.Base(msg.download.Path))
+	return m.watchDownloads()
+}
+
+// watchDownloads starts the progress ticks unless they are running
+func (m *Model) watchDownloads() tea.Cmd {
+	if m.downloadsWatched {
+		return nil
+	}
+	m.downloadsWatched = true
+	return tea.Tick(downloadTickInterval, func(time.Time) tea.Msg {
+		return downloadTickMsg{}
+	})
+}
+
+// updateDownloads reports finished downloads, refreshes about:downloads
+// and keeps ticking while any download is active
+func (m *Model) updateDownloads() tea.Cmd {
+	m.downloadsWatched = false
+
+	for _, dl := range m.downloads.List() {
+		if dl.State == storage.DownloadActive || dl.ID <= m.downloadsReported {
+			continue
+		}
+		m.downloadsReported = dl.ID
+		switch dl.State {
+		case storage.DownloadDone:
+			m.statusMsg = fmt.Sprintf("Downloaded %s (%s)", dl.Path, formatBytes(dl.Bytes))
+		case storage.DownloadFailed:
+			m.statusMsg = fmt.Sprintf("Error: download of %s failed: %v", dl.URL, dl.Err)
+		}
+	}
+
+	if m.currentURL == "about:downloads" && !m.loading {
+		m.refreshAbout("downloads")
+	}
+
+	if m.downloads.Active() == 0 {
+		return nil
+	}
+	return m.watchDownloads()
+}
+
+// refreshAbout regenerates the about: page being shown, keeping the scroll
+// position and selected link
+func (m *Model) refreshAbout(page string) {
+	content, err := m.aboutPage(page, &url.URL{Scheme: "about", Opaque: page})
+	if err != nil {
+		return
+	}
+	doc, err := parser.Parse(strings.NewReader(content))
+	if err != nil {
+		return
+	}
+
+	offset := m.viewport.YOffset
+	m.document = doc
+	m.rawContent = content
+	if m.selectedLink >= doc.LinkCount() {
+		m.selectedLink = -1
+	}
+	m.renderDocument()
+	m.viewport.SetYOffset(offset)
+}
+
+// downloadsStatus summarizes active downloads for the status bar
+func (m Model) downloadsStatus() string {
+	active, bytes := 0, int64(0)
+	for _, dl := range m.downloads.List() {
+		if dl.State == storage.DownloadActive {
+			active++
+			bytes += dl.Bytes
+		}
+	}
+	if active == 0 {
+		return ""
+	}
+	return fmt.Sprintf("↓ %d (%s) | ", active, formatBytes(bytes))
+}
+
+// aboutDownloadsPage lists this session's downloads, active ones first
+func (m *Model) aboutDownloadsPage() string {
+	var b strings.Builder
+	b.WriteString("# Downloads\n\n")
+
+	dir := m.downloads.Dir()
+	fmt.Fprintf(&b, "=> %s Files are saved to %s\n\n", (&url.URL{Scheme: "file", Path: dir}).String(), dir)
+
+	downloads := m.downloads.List()
+	if len(downloads) == 0 {
+		b.WriteString("No downloads in this session.\n")
+		return b.String()
+	}
+
+	b.WriteString("## Active\n\n")
+	active := 0
+	for _, dl := range downloads {
+		if dl.State != storage.DownloadActive {
+			continue
+		}
+		active++
+		fmt.Fprintf(&b, "### %s\n", filepath.Base(dl.Path))
+		fmt.Fprintf(&b, "* %s received, %s/s\n", formatBytes(dl.Bytes), formatBytes(int64(dl.Rate())))
+		fmt.Fprintf(&b, "=> %s From %s\n", dl.URL, dl.URL)
+		fmt.Fprintf(&b, "=> %s Cancel\n\n", aboutURL("downloads", "cancel", strconv.Itoa(dl.ID)))
+	}
+	if active == 0 {
+		b.WriteString("None.\n\n")
+	}
+
+	b.WriteString("## Finished\n\n")
+	finished := 0
+	for i := len(downloads) - 1; i >= 0; i-- {
+		dl := downloads[i]
+		if dl.State == storage.DownloadActive {
+			continue
+		}
+		finished++
+		switch dl.State {
+		case storage.DownloadDone:
+			fmt.Fprintf(&b, "=> %s %s (%s, %s)\n", (&url.URL{Scheme: "file", Path: dl.Path}).String(),
+				filepath.Base(dl.Path), formatBytes(dl.Bytes), dl.Finished.Sub(dl.Started).Round(time.Second/10))
+		case storage.DownloadFailed:
+			fmt.Fprintf(&b, "* %s failed after %s: %v\n", filepath.Base(dl.Path), formatBytes(dl.Bytes), dl.Err)
+		case storage.DownloadCancelled:
+			fmt.Fprintf(&b, "* %s cancelled after %s\n", filepath.Base(dl.Path), formatBytes(dl.Bytes))
+		}
+	}
+	if finished == 0 {
+		b.WriteString("None.\n")
+	} else {
+		b.WriteString("\n=> about:downloads/clear Clear finished downloads from this list\n")
+	}
+
+	return b.String()
+}
+
+// downloadsAction runs an about:downloads action
+func (m *Model) downloadsAction(args []string, rawURL string) (*protocol.Response, string, error) {
+	switch {
+	case args[0] == "cancel" && len(args) == 2:
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid download: %s", args[1])
+		}
+		if err := m.downloads.Cancel(id); err != nil {
+			return nil, "", err
+		}
+		return aboutRedirect(rawURL, "about:downloads"), "Download cancelled", nil
+
+	case args[0] == "clear":
+		m.downloads.ClearFinished()
+		return aboutRedirect(rawURL, "about:downloads"), "Cleared finished downloads", nil
+	}
+
+	return nil, "", fmt.Errorf("unknown action: %s", rawURL)
+}
+
+// downloadsDir returns the configured downloads directory, or the default
+func downloadsDir(cfg *config.Config) string {
+	if cfg.Downloads.Dir != "" {
+		return expandHome(cfg.Downloads.Dir)
+	}
+	dir, err := config.DownloadsDir()
+	if err != nil {
+		return "."
+	}
+	return dir
+}
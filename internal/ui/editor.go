@@ -0,0 +1,185 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/watson-ij/gemini/internal/protocol"
+)
+
+// defaultEditor is used when neither $VISUAL nor $EDITOR is set
+const defaultEditor = "vi"
+
+// editText suspends the TUI, opens text in the user's editor and, once the
+// editor exits, delivers the edited text through done
+func editText(text, suffix string, done func(edited string, err error) tea.Msg) tea.Cmd {
+	f, err := os.CreateTemp("", "gemini-*"+suffix)
+	if err != nil {
+		return func() tea.Msg { return done("", err) }
+	}
+	path := f.Name()
+
+	_, err = f.WriteString(text)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return func() tea.Msg { return done("", err) }
+	}
+
+	return tea.ExecProcess(editorCommand(path), func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return done("", fmt.Errorf("editor failed: %w", err))
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return done("", err)
+		}
+		return done(string(data), nil)
+	})
+}
+
+// editorCommand builds the command that opens path in the user's editor
+// $VISUAL and $EDITOR may include arguments (e.g. "code --wait")
+func editorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		args = []string{defaultEditor}
+	}
+
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
+// pageEditedMsg is sent when the editor opened to edit the current page exits
+type pageEditedMsg struct {
+	url      string
+	original string
+	edited   string
+	err      error
+}
+
+// uploadedMsg is sent when a Titan upload completes
+type uploadedMsg struct {
+	// url is the page to show after the upload
+	url string
+}
+
+// editPage opens the current page's gemtext in the editor so that it can
+// be uploaded back to the server with Titan
+func (m *Model) editPage() tea.Cmd {
+	if m.document == nil || m.loading {
+		return nil
+	}
+	if _, err := protocol.TitanURL(m.currentURL); err != nil {
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return nil
+	}
+
+	// Uploading a page that was stopped, cut short or isn't gemtext would
+	// replace the original with something else
+	if !m.rawComplete {
+		m.statusMsg = "Error: the page was not received in full, reload it to edit"
+		return nil
+	}
+	resp := m.info.resp
+	if resp == nil || !resp.Status.IsSuccess() || !strings.EqualFold(contentType(resp), "text/gemini") {
+		m.statusMsg = "Error: only gemtext pages can be edited"
+		return nil
+	}
+
+	pageURL, original := m.currentURL, m.rawContent
+	return editText(original, ".gmi", func(edited string, err error) tea.Msg {
+		return pageEditedMsg{url: pageURL, original: original, edited: edited, err: err}
+	})
+}
+
+// uploadPage uploads an edited page to the titan:// URL matching its gemini:// URL
+func (m *Model) uploadPage(msg pageEditedMsg) tea.Cmd {
+	if msg.err != nil {
+		m.err = msg.err
+		m.statusMsg = fmt.Sprintf("Error: %v", msg.err)
+		return nil
+	}
+	if msg.edited == msg.original {
+		m.statusMsg = "No changes to upload"
+		return nil
+	}
+
+	titanURL, err := protocol.TitanURL(msg.url)
+	if err != nil {
+		m.err = err
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return nil
+	}
+
+	token := ""
+	if u, err := url.Parse(msg.url); err == nil {
+		token = m.config.Titan.Tokens[u.Hostname()]
+	}
+
+	ctx := m.beginRequest()
+	m.statusMsg = "Uploading..."
+
+	client := m.client
+	data := []byte(msg.edited)
+	return func() tea.Msg {
+		resp, err := client.Upload(ctx, titanURL, &protocol.TitanUpload{
+			Body:  bytes.NewReader(data),
+			Size:  int64(len(data)),
+			MIME:  protocol.DefaultTitanMIME,
+			Token: token,
+		})
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("upload failed: %w", err)}
+		}
+		resp.Close()
+
+		switch {
+		case resp.Status.IsRedirect():
+			target, err := url.Parse(msg.url)
+			if err != nil {
+				return errorMsg{err: err}
+			}
+			ref, err := url.Parse(resp.Meta)
+			if err != nil {
+				return errorMsg{err: fmt.Errorf("invalid redirect URL: %w", err)}
+			}
+			return uploadedMsg{url: target.ResolveReference(ref).String()}
+
+		case resp.Status.IsSuccess():
+			return uploadedMsg{url: msg.url}
+
+		default:
+			return errorMsg{err: fmt.Errorf("upload failed: %w", resp.Err())}
+		}
+	}
+}
+
+// finishUpload shows the uploaded page, noting the upload in the status
+// bar once the page has loaded
+func (m *Model) finishUpload(msg uploadedMsg) tea.Cmd {
+	m.finishLoading()
+	m.statusMsg = "Uploaded"
+
+	load := m.loadURL(msg.url)
+	return func() tea.Msg {
+		loaded := load()
+		if start, ok := loaded.(streamStartMsg); ok {
+			start.stream.notes = append(start.stream.notes, "uploaded")
+		}
+		return loaded
+	}
+}
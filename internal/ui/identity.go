@@ -0,0 +1,263 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/watson-ij/gemini/internal/protocol"
+)
+
+// defaultIdentityDays is the lifetime offered for new identities
+const defaultIdentityDays = int(protocol.DefaultIdentityLifetime / (24 * time.Hour))
+
+// certRequiredMsg is sent when a request is answered with a 6x status
+type certRequiredMsg struct {
+	url    string
+	status protocol.StatusCode
+	meta   string
+}
+
+// identitiesPage generates about:identities. When forURL is set the page
+// was opened because that URL asked for a client certificate, and offers
+// to use an identity for it
+func (m *Model) identitiesPage(forURL string) string {
+	var b strings.Builder
+
+	// Identities are only offered for the URL that asked for one
+	if forURL != m.certRequest.url {
+		forURL = ""
+	}
+
+	if forURL != "" {
+		req := m.certRequest
+		b.WriteString("# Client Certificate Requested\n\n")
+		fmt.Fprintf(&b, "=> %s %s\n\n", gemtextLink(forURL), gemtextText(forURL))
+		fmt.Fprintf(&b, "The server responded: %d %s\n", req.status, req.status)
+		if req.meta != "" {
+			fmt.Fprintf(&b, "> %s\n", gemtextText(req.meta))
+		}
+		b.WriteString("\n")
+		b.WriteString("Choose an identity to send with this request.\n\n")
+	} else {
+		b.WriteString("# Identities\n\n")
+	}
+
+	identities := m.identities.List()
+	if len(identities) == 0 {
+		b.WriteString("No identities yet.\n\n")
+	}
+
+	for _, id := range identities {
+		fmt.Fprintf(&b, "## %s\n\n", gemtextText(id.Name))
+		fmt.Fprintf(&b, "* Key type: %s\n", id.KeyType())
+		if leaf, err := id.Leaf(); err == nil {
+			fmt.Fprintf(&b, "* Common name: %s\n", gemtextText(leaf.Subject.CommonName))
+		}
+		fmt.Fprintf(&b, "* Expires: %s\n", identityExpiry(id))
+		fmt.Fprintf(&b, "* Fingerprint: %s\n", id.Fingerprint())
+		for _, scope := range m.identities.Scopes(id.Name) {
+			fmt.Fprintf(&b, "* Used for %s\n", gemtextText(scope))
+		}
+
+		if forURL != "" {
+			fmt.Fprintf(&b, "=> %s Use for this request\n", aboutURL("identities", "use", id.Name, forURL))
+		}
+		fmt.Fprintf(&b, "=> %s Rename\n", aboutURL("identities", "rename", id.Name))
+		fmt.Fprintf(&b, "=> %s Export\n", aboutURL("identities", "export", id.Name))
+		fmt.Fprintf(&b, "=> %s Delete\n\n", aboutURL("identities", "delete", id.Name))
+	}
+
+	// Identities whose files are damaged are left on disk to be repaired
+	if errs := m.identities.LoadErrors(); len(errs) > 0 {
+		b.WriteString("## Could not be loaded\n\n")
+		for _, err := range errs {
+			fmt.Fprintf(&b, "* %s\n", gemtextText(err.Error()))
+		}
+		b.WriteString("\n")
+	}
+
+	// A new identity created for a request is used for it straight away
+	b.WriteString("## New identity\n\n")
+	for _, kt := range []struct {
+		keyType protocol.KeyType
+		label   string
+	}{
+		{protocol.KeyEd25519, "Ed25519"},
+		{protocol.KeyECDSA, "ECDSA P-256"},
+	} {
+		fmt.Fprintf(&b, "=> %s Create an %s identity\n", aboutURL("identities", "new", string(kt.keyType), forURL), kt.label)
+	}
+	b.WriteString("=> about:identities/import Import an identity from a PEM file\n")
+
+	return b.String()
+}
+
+// identityAction runs an about:identities action. Actions that need more
+// information ask for it with an input prompt and are run again with the
+// answer as the query
+func (m *Model) identityAction(args []string, rawURL, query string, answered bool) (*protocol.Response, string, error) {
+	switch {
+	// new/<key type>/<requesting URL or empty> asks for the name, common
+	// name and lifetime in turn, adding each answer to the path
+	case args[0] == "new" && len(args) >= 3 && len(args) <= 5:
+		answers := args[3:]
+		switch len(answers) {
+		case 0:
+			if !answered || query == "" {
+				return aboutInput(rawURL, "Name for the new identity"), "", nil
+			}
+			if _, exists := m.identities.Get(query); exists {
+				return nil, "", fmt.Errorf("identity %q already exists", query)
+			}
+		case 1:
+			if !answered {
+				return aboutInput(rawURL, "Common name for the certificate (leave empty to use the name)"), "", nil
+			}
+		default:
+			if !answered {
+				return aboutInput(rawURL, fmt.Sprintf("Lifetime in days (leave empty for %d)", defaultIdentityDays)), "", nil
+			}
+			id, err := m.createIdentity(answers[0], answers[1], query, protocol.KeyType(args[1]))
+			if err != nil {
+				return nil, "", err
+			}
+			status := fmt.Sprintf("Created identity %q", id.Name)
+			if args[2] != "" {
+				return m.useIdentity(rawURL, id.Name, args[2], status)
+			}
+			return aboutRedirect(rawURL, "about:identities"), status, nil
+		}
+		return aboutRedirect(rawURL, aboutURL("identities", append(args, query)...)), "", nil
+
+	case args[0] == "use" && len(args) == 3:
+		return m.useIdentity(rawURL, args[1], args[2], "")
+
+	case args[0] == "rename" && len(args) == 2:
+		if !answered || query == "" {
+			return aboutInput(rawURL, fmt.Sprintf("New name for %q", args[1])), "", nil
+		}
+		if err := m.identities.Rename(args[1], query); err != nil {
+			return nil, "", err
+		}
+		return aboutRedirect(rawURL, "about:identities"), fmt.Sprintf("Renamed identity %q to %q", args[1], query), nil
+
+	case args[0] == "export" && len(args) == 2:
+		if !answered || query == "" {
+			return aboutInput(rawURL, fmt.Sprintf("File to export %q to (e.g. ~/%s.pem)", args[1], args[1])), "", nil
+		}
+		data, err := m.identities.Export(args[1])
+		if err != nil {
+			return nil, "", err
+		}
+		path := expandHome(query)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return nil, "", err
+		}
+		return aboutRedirect(rawURL, "about:identities"), fmt.Sprintf("Exported identity %q to %s", args[1], path), nil
+
+	case args[0] == "import" && len(args) == 1:
+		if !answered || query == "" {
+			return aboutInput(rawURL, "PEM file holding the certificate and key"), "", nil
+		}
+		return aboutRedirect(rawURL, aboutURL("identities", "import", query)), "", nil
+
+	case args[0] == "import" && len(args) == 2:
+		if !answered {
+			return aboutInput(rawURL, "Name for the identity (leave empty to use the file name)"), "", nil
+		}
+		path := expandHome(args[1])
+		name := query
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := m.identities.Import(name, data); err != nil {
+			return nil, "", err
+		}
+		return aboutRedirect(rawURL, "about:identities"), fmt.Sprintf("Imported identity %q", name), nil
+
+	case args[0] == "delete" && len(args) == 2:
+		if !answered {
+			return aboutInput(rawURL, fmt.Sprintf("Delete identity %q? Type yes to confirm", args[1])), "", nil
+		}
+		if !strings.EqualFold(query, "yes") {
+			return aboutRedirect(rawURL, "about:identities"), "Cancelled", nil
+		}
+		if err := m.identities.Delete(args[1]); err != nil {
+			return nil, "", err
+		}
+		return aboutRedirect(rawURL, "about:identities"), fmt.Sprintf("Deleted identity %q", args[1]), nil
+	}
+
+	return nil, "", fmt.Errorf("unknown action: %s", rawURL)
+}
+
+// createIdentity generates and stores a new identity. An empty common name
+// defaults to the identity name, and an empty lifetime to a year
+func (m *Model) createIdentity(name, commonName, days string, keyType protocol.KeyType) (*protocol.Identity, error) {
+	lifetime := protocol.DefaultIdentityLifetime
+	if days != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(days))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid lifetime: %q", days)
+		}
+		lifetime = time.Duration(n) * 24 * time.Hour
+	}
+
+	id, err := protocol.GenerateIdentity(name, protocol.IdentityOptions{
+		CommonName: commonName,
+		KeyType:    keyType,
+		Lifetime:   lifetime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.identities.Add(id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// useIdentity scopes the named identity to the requesting URL and retries it
+func (m *Model) useIdentity(rawURL, name, target, status string) (*protocol.Response, string, error) {
+	if err := m.identities.Use(name, target); err != nil {
+		return nil, "", err
+	}
+	if status == "" {
+		status = fmt.Sprintf("Using identity %q", name)
+	}
+	return aboutRedirect(rawURL, target), status, nil
+}
+
+// expandHome expands a leading ~ in a path to the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// identityExpiry formats when an identity's certificate expires
+func identityExpiry(id *protocol.Identity) string {
+	leaf, err := id.Leaf()
+	if err != nil {
+		return "unknown"
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return "expired " + leaf.NotAfter.Format("2006-01-02")
+	}
+	return leaf.NotAfter.Format("2006-01-02")
+}
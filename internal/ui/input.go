@@ -0,0 +1,249 @@
+package ui
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// inputPrompt holds the state of the prompt shown for a 1x response
+type inputPrompt struct {
+	// url is the URL that asked for input; the answer is sent as its query
+	url string
+
+	// prompt is the question from the response meta
+	prompt string
+
+	// sensitive is set for status 11, whose answer is masked
+	sensitive bool
+
+	// answers are earlier answers to the same URL, oldest first, and
+	// recalled is the position of the answer shown (len(answers) = none)
+	answers  []string
+	recalled int
+
+	input textinput.Model
+}
+
+// maxAnswers is the number of answers remembered for each URL
+const maxAnswers = 20
+
+// maxRequestLength is the longest URL a Gemini request may carry
+const maxRequestLength = 1024
+
+// inputRequiredMsg is sent when a request is answered with a 1x status
+type inputRequiredMsg struct {
+	url       string
+	prompt    string
+	sensitive bool
+}
+
+// inputEditedMsg is sent when the editor opened for a long answer exits
+type inputEditedMsg struct {
+	answer string
+	err    error
+}
+
+// openInputPrompt switches to the input prompt for a 1x response
+func (m *Model) openInputPrompt(msg inputRequiredMsg) tea.Cmd {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.Width = max(m.width-8, 20)
+	if msg.sensitive {
+		ti.EchoMode = textinput.EchoPassword
+		ti.EchoCharacter = '•'
+	}
+	ti.Focus()
+
+	// Sensitive answers are never remembered
+	var answers []string
+	if !msg.sensitive {
+		answers = m.answers[answerKey(msg.url)]
+	}
+
+	m.prompt = inputPrompt{
+		url:       msg.url,
+		prompt:    msg.prompt,
+		sensitive: msg.sensitive,
+		answers:   answers,
+		recalled:  len(answers),
+		input:     ti,
+	}
+	m.mode = ModeInput
+	m.statusMsg = "Input requested"
+	return textinput.Blink
+}
+
+// updateInputPrompt handles keys while the input prompt is open
+func (m Model) updateInputPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		return m, m.submitInput(m.prompt.input.Value())
+
+	case "esc":
+		m.mode = ModeBrowse
+		m.statusMsg = "Input cancelled"
+		return m, nil
+
+	case "up", "down":
+		m.recallAnswer(msg.String() == "up")
+		return m, nil
+
+	case "ctrl+x":
+		// The editor writes the answer to a temporary file
+		if m.prompt.sensitive {
+			m.statusMsg = "Sensitive input cannot be edited in $EDITOR"
+			return m, nil
+		}
+		return m, editText(m.prompt.input.Value(), ".txt", func(edited string, err error) tea.Msg {
+			return inputEditedMsg{answer: edited, err: err}
+		})
+	}
+
+	var cmd tea.Cmd
+	m.prompt.input, cmd = m.prompt.input.Update(msg)
+	return m, cmd
+}
+
+// submitInput sends an answer to the prompt's URL as its query
+func (m *Model) submitInput(answer string) tea.Cmd {
+	target, err := inputURL(m.prompt.url, answer)
+	if err == nil && len(target) > maxRequestLength {
+		err = fmt.Errorf("answer is too long: the request would be %d bytes, the limit is %d", len(target), maxRequestLength)
+	}
+	if err != nil {
+		m.err = err
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return nil
+	}
+
+	m.mode = ModeBrowse
+	if !m.prompt.sensitive {
+		m.rememberAnswer(m.prompt.url, answer)
+	}
+	return m.loadURL(target)
+}
+
+// finishInputEdit submits an answer written in the editor
+func (m *Model) finishInputEdit(msg inputEditedMsg) tea.Cmd {
+	if m.mode != ModeInput {
+		return nil
+	}
+	if msg.err != nil {
+		m.err = msg.err
+		m.statusMsg = fmt.Sprintf("Error: %v", msg.err)
+		return nil
+	}
+
+	// Editors end the file with a newline that is not part of the answer
+	answer := strings.TrimSuffix(msg.answer, "\n")
+	m.prompt.input.SetValue(answer)
+	return m.submitInput(answer)
+}
+
+// recallAnswer steps through earlier answers to the prompt's URL, towards
+// older answers or back towards an empty prompt
+func (m *Model) recallAnswer(older bool) {
+	p := &m.prompt
+	switch {
+	case older && p.recalled > 0:
+		p.recalled--
+	case !older && p.recalled < len(p.answers):
+		p.recalled++
+	default:
+		return
+	}
+
+	if p.recalled == len(p.answers) {
+		p.input.SetValue("")
+	} else {
+		p.input.SetValue(p.answers[p.recalled])
+	}
+	p.input.CursorEnd()
+}
+
+// rememberAnswer adds an answer to the URL's history, moving a repeated
+// answer to the end
+func (m *Model) rememberAnswer(rawURL, answer string) {
+	if answer == "" || isAboutURL(rawURL) {
+		return
+	}
+	if m.answers == nil {
+		m.answers = make(map[string][]string)
+	}
+
+	key := answerKey(rawURL)
+	var answers []string
+	for _, a := range m.answers[key] {
+		if a != answer {
+			answers = append(answers, a)
+		}
+	}
+	answers = append(answers, answer)
+	if len(answers) > maxAnswers {
+		answers = answers[len(answers)-maxAnswers:]
+	}
+	m.answers[key] = answers
+}
+
+// answerKey is the URL answers are remembered under: the URL that asked,
+// without the query holding an earlier answer
+func answerKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	return u.String()
+}
+
+// inputURL returns rawURL with its query replaced by the percent-encoded answer
+func inputURL(rawURL, answer string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	// Spaces must be %20, not the "+" used in form encoding
+	u.RawQuery = strings.ReplaceAll(url.QueryEscape(answer), "+", "%20")
+	u.ForceQuery = true // an empty answer is still sent
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// inputView renders the input prompt
+func (m Model) inputView() string {
+	var b strings.Builder
+
+	title := m.styles.TitleBar.Render("Input Requested - ESC to cancel")
+
+	fmt.Fprintf(&b, "%s\n\n", m.prompt.url)
+	prompt := m.prompt.prompt
+	if prompt == "" {
+		prompt = "Enter input:"
+	}
+	b.WriteString(prompt + "\n\n")
+	b.WriteString(m.prompt.input.View() + "\n")
+
+	if m.prompt.sensitive {
+		b.WriteString("\n(sensitive input is hidden)\n")
+		b.WriteString("\nenter: submit | esc: cancel")
+	} else {
+		if n := len(m.prompt.answers); n > 0 {
+			fmt.Fprintf(&b, "\n(%d earlier answers: up/down to recall)\n", n)
+		}
+		b.WriteString("\nenter: submit | ctrl+x: write in $EDITOR | esc: cancel")
+	}
+
+	content := lipgloss.NewStyle().
+		Padding(1, 2).
+		Render(b.String())
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, content)
+}
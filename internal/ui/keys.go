@@ -26,6 +26,7 @@ type KeyMap struct {
 	Back         key.Binding
 	Forward      key.Binding
 	Reload       key.Binding
+	Stop         key.Binding
 	GoHome       key.Binding
 
 	// Tabs
@@ -49,9 +50,13 @@ type KeyMap struct {
 	ShowHistory    key.Binding
 
 	// Other
-	Find  key.Binding
-	Help  key.Binding
-	Quit  key.Binding
+	EditPage   key.Binding
+	Find       key.Binding
+	Identities key.Binding
+	KnownHosts key.Binding
+	PageInfo   key.Binding
+	Help       key.Binding
+	Quit       key.Binding
 }
 
 // DefaultKeyMap returns the default key bindings
@@ -126,6 +131,10 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("ctrl+r"),
 			key.WithHelp("ctrl+r", "reload"),
 		),
+		Stop: key.NewBinding(
+			key.WithKeys("esc", "ctrl+c"),
+			key.WithHelp("esc", "stop loading"),
+		),
 		GoHome: key.NewBinding(
 			key.WithKeys("ctrl+h"),
 			key.WithHelp("ctrl+h", "home"),
@@ -168,8 +177,8 @@ func DefaultKeyMap() KeyMap {
 			key.WithHelp("ctrl+b", "bookmarks"),
 		),
 		ShowHistory: key.NewBinding(
-			key.WithKeys("ctrl+shift+h"),
-			key.WithHelp("ctrl+shift+h", "history"),
+			key.WithKeys("ctrl+shift+h", "H"),
+			key.WithHelp("H", "history"),
 		),
 
 		// Other
@@ -177,6 +186,22 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("ctrl+f", "/"),
 			key.WithHelp("ctrl+f", "find"),
 		),
+		EditPage: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "edit page (titan)"),
+		),
+		Identities: key.NewBinding(
+			key.WithKeys("I"),
+			key.WithHelp("I", "identities"),
+		),
+		KnownHosts: key.NewBinding(
+			key.WithKeys("K"),
+			key.WithHelp("K", "known hosts"),
+		),
+		PageInfo: key.NewBinding(
+			key.WithKeys("i"),
+			key.WithHelp("i", "page info"),
+		),
 		Help: key.NewBinding(
 			key.WithKeys("?"),
 			key.WithHelp("?", "help"),
@@ -203,8 +228,8 @@ func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Up, k.Down, k.PageUp, k.PageDown},
 		{k.Home, k.End, k.NextLink, k.PrevLink},
-		{k.FocusAddress, k.Back, k.Forward, k.Reload},
-		{k.NewTab, k.CloseTab, k.NextTab, k.BookmarkPage},
-		{k.Find, k.Help, k.Quit},
+		{k.FocusAddress, k.Back, k.Forward, k.Reload, k.Stop},
+		{k.NewTab, k.CloseTab, k.NextTab, k.BookmarkPage, k.ShowHistory},
+		{k.Find, k.EditPage, k.Identities, k.KnownHosts, k.PageInfo, k.Help, k.Quit},
 	}
 }
@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/watson-ij/gemini/internal/protocol"
+)
+
+// pageInfo describes how the current page was fetched, for the page-info panel
+type pageInfo struct {
+	// url is the URL shown in the address bar
+	url string
+
+	// resp is the response header (its body is not used)
+	resp *protocol.Response
+
+	// started is when the request was sent
+	started time.Time
+
+	// header is how long the response header took to arrive
+	header time.Duration
+
+	// total is how long the whole page took (0 while it is loading)
+	total time.Duration
+
+	// cached is when the page was fetched, if it was shown from the cache
+	cached time.Time
+}
+
+// openPageInfo shows the page-info panel
+func (m *Model) openPageInfo() {
+	if m.info.url == "" {
+		m.statusMsg = "No page information"
+		return
+	}
+	m.mode = ModePageInfo
+}
+
+// updatePageInfo handles keys while the page-info panel is open
+func (m Model) updatePageInfo(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.PageInfo), msg.String() == "esc", msg.String() == "q":
+		m.mode = ModeBrowse
+	}
+	return m, nil
+}
+
+// pageInfoView renders the page-info panel
+func (m Model) pageInfoView() string {
+	title := m.styles.TitleBar.Render("Page Info - ESC to close")
+
+	var b strings.Builder
+	info := m.info
+	row := func(label, format string, args ...any) {
+		fmt.Fprintf(&b, "  %-14s %s\n", label+":", fmt.Sprintf(format, args...))
+	}
+
+	b.WriteString("Page\n")
+	row("URL", "%s", info.url)
+	if resp := info.resp; resp != nil {
+		row("Status", "%d %s", resp.Status, resp.Status)
+		for i, r := range resp.Redirects {
+			kind := "temporary"
+			if r.Permanent {
+				kind = "permanent"
+			}
+			row(fmt.Sprintf("Redirect %d", i+1), "%s (%s)", r.URL, kind)
+		}
+		if resp.Proxy != "" {
+			row("Proxy", "%s", resp.Proxy)
+		}
+
+		mimeType, params, err := mime.ParseMediaType(resp.Meta)
+		if err != nil {
+			mimeType = resp.MIMEType()
+		}
+		row("MIME type", "%s", mimeType)
+		for _, name := range sortedKeys(params) {
+			row("  "+name, "%s", params[name])
+		}
+	}
+	if m.document != nil {
+		row("Size", "%s, %d lines, %d links", formatBytes(int64(len(m.rawContent))), m.document.LineCount(), m.document.LinkCount())
+	}
+
+	b.WriteString("\nTiming\n")
+	switch {
+	case !info.cached.IsZero():
+		row("Cached", "fetched %s (%s ago)", info.cached.Format("2006-01-02 15:04:05"), time.Since(info.cached).Round(time.Second))
+	case info.started.IsZero():
+		row("Generated", "locally")
+	default:
+		row("Requested", "%s", info.started.Format("2006-01-02 15:04:05"))
+		row("Header", "%s", info.header.Round(time.Millisecond))
+		if info.total > 0 {
+			row("Complete", "%s", info.total.Round(time.Millisecond))
+		} else {
+			row("Complete", "still loading")
+		}
+	}
+
+	if info.resp != nil && info.resp.TLSState != nil {
+		m.writeTLSInfo(&b, row, info.resp)
+	}
+
+	content := lipgloss.NewStyle().
+		Padding(1, 2).
+		Render(b.String())
+
+	return lipgloss.JoinVertical(lipgloss.Left, title, content)
+}
+
+// writeTLSInfo adds the connection and certificate sections of the panel
+func (m Model) writeTLSInfo(b *strings.Builder, row func(label, format string, args ...any), resp *protocol.Response) {
+	state := resp.TLSState
+
+	b.WriteString("\nConnection\n")
+	row("TLS version", "%s", tls.VersionName(state.Version))
+	row("Cipher suite", "%s", tls.CipherSuiteName(state.CipherSuite))
+	if state.ServerName != "" {
+		row("Server name", "%s", state.ServerName)
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+	cert := state.PeerCertificates[0]
+
+	b.WriteString("\nCertificate\n")
+	row("Subject", "%s", cert.Subject)
+	if cert.Issuer.String() != cert.Subject.String() {
+		row("Issuer", "%s", cert.Issuer)
+	} else {
+		row("Issuer", "self-signed")
+	}
+	if len(cert.DNSNames) > 0 {
+		row("Names", "%s", strings.Join(cert.DNSNames, ", "))
+	}
+	validity := cert.NotBefore.Format("2006-01-02") + " to " + cert.NotAfter.Format("2006-01-02")
+	if time.Now().After(cert.NotAfter) {
+		validity += " (expired)"
+	}
+	row("Valid", "%s", validity)
+	row("Fingerprint", "%s", protocol.CertificateFingerprint(cert))
+	row("Key", "%s", protocol.PublicKeyFingerprint(cert))
+	row("Trust", "%s", m.trustStatus(resp, cert))
+}
+
+// trustStatus describes how the TOFU store regards a response's certificate
+func (m Model) trustStatus(resp *protocol.Response, cert *x509.Certificate) string {
+	if m.client.TOFU == nil {
+		return "not verified (TOFU disabled)"
+	}
+
+	// Requests through a proxy verify the proxy's certificate
+	host := resp.Proxy
+	if host == "" {
+		if u, err := url.Parse(resp.URL); err == nil {
+			host = protocol.OriginHost(u)
+		}
+	}
+	fingerprint := protocol.CertificateFingerprint(cert)
+	known, ok := m.client.TOFU.GetCertificateInfo(host)
+	switch {
+	case !ok:
+		return "not in known hosts"
+	case !known.Matches(fingerprint, protocol.PublicKeyFingerprint(cert)):
+		return "does not match the known certificate"
+	case known.Fingerprint != fingerprint:
+		return fmt.Sprintf("trusted (%s), renewed with the known key", known.Trust)
+	}
+	return fmt.Sprintf("trusted (%s), first seen %s", known.Trust, known.FirstSeen.Format("2006-01-02"))
+}
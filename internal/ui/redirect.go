@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/watson-ij/gemini/internal/parser"
+	"github.com/watson-ij/gemini/internal/protocol"
+)
+
+// redirectMsg is sent when a redirect to another host or scheme was not
+// followed, so the user can decide whether to go there
+type redirectMsg struct {
+	resp *protocol.Response
+}
+
+// approveRedirect is the client's OnRedirect hook. Requests run outside
+// the update loop and cannot ask the user, so redirects that leave the host
+// or scheme are not followed by the client. The response comes back as a
+// redirectMsg instead, and showRedirect asks the user whether to follow it
+func approveRedirect(from, to string, permanent bool) bool {
+	return false
+}
+
+// followRedirects moves the current page to the URL a redirected response
+// finally came from, and rewrites bookmarks of pages that have permanently
+// moved. It returns a note about the redirects for the status bar
+func (m *Model) followRedirects(resp *protocol.Response) string {
+	moved := 0
+	for _, r := range resp.Redirects {
+		if r.Permanent {
+			moved += m.pageMoved(r.URL, r.Target)
+		}
+	}
+
+	if len(resp.Redirects) == 0 || resp.URL == m.currentURL {
+		return ""
+	}
+
+	// The redirecting URL is replaced in the history by its destination
+	if m.historyPos >= 0 && m.history[m.historyPos] == m.currentURL {
+		m.history[m.historyPos] = resp.URL
+	}
+	if n := len(m.visits); n > 0 && m.visits[n-1].url == m.currentURL {
+		m.visits[n-1].url = resp.URL
+	}
+	from := m.currentURL
+	m.currentURL = resp.URL
+	m.addressBar.SetValue(resp.URL)
+
+	note := "redirected from " + from
+	if moved > 0 {
+		note += fmt.Sprintf(", %d bookmark(s) updated", moved)
+	}
+	return note
+}
+
+// pageMoved points bookmarks of a permanently moved page at its new URL,
+// returning the number of bookmarks changed
+func (m *Model) pageMoved(from, to string) int {
+	moved, err := m.bookmarks.Move(from, to)
+	if err != nil {
+		m.err = err
+		return 0
+	}
+	if moved {
+		return 1
+	}
+	return 0
+}
+
+// showRedirect asks whether to follow a redirect that leaves the host or
+// scheme of the page that sent it. The page is replaced by one linking to
+// the destination, so it can still be followed after saying no
+func (m *Model) showRedirect(msg redirectMsg) tea.Cmd {
+	m.finishLoading()
+	m.followRedirects(msg.resp)
+
+	// Bookmarks only move once the user has followed a permanent redirect
+	permanent := msg.resp.Status == protocol.StatusRedirectPermanent
+	m.info = pageInfo{url: m.currentURL, resp: msg.resp}
+
+	var b strings.Builder
+	b.WriteString("# Redirect\n\n")
+	kind := "temporarily"
+	if permanent {
+		kind = "permanently"
+	}
+	fmt.Fprintf(&b, "This page has %s moved to another site:\n\n", kind)
+	fmt.Fprintf(&b, "=> %s %s\n\n", gemtextLink(msg.resp.Meta), gemtextText(msg.resp.Meta))
+	b.WriteString("Follow the link to continue.\n")
+
+	content := b.String()
+	doc, err := parser.Parse(strings.NewReader(content))
+	if err != nil {
+		m.err = err
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return nil
+	}
+
+	// The question is only asked while browsing, not over another prompt
+	if m.mode == ModeBrowse {
+		m.redirect = msg
+		m.mode = ModeRedirectPrompt
+	}
+
+	return func() tea.Msg {
+		return pageLoadedMsg{doc: doc, raw: content, status: "Redirect to " + msg.resp.Meta + " not followed"}
+	}
+}
+
+// updateRedirectPrompt handles keys while a redirect awaits confirmation
+func (m Model) updateRedirectPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	resp := m.redirect.resp
+	switch msg.String() {
+	case "y", "enter":
+		m.mode = ModeBrowse
+		m.redirect = redirectMsg{}
+		if resp.Status == protocol.StatusRedirectPermanent {
+			m.pageMoved(resp.URL, resp.Meta)
+		}
+		return m, m.loadURL(resp.Meta)
+	case "n", "esc":
+		m.mode = ModeBrowse
+		m.redirect = redirectMsg{}
+		m.statusMsg = "Redirect to " + resp.Meta + " not followed"
+	}
+	return m, nil
+}
@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// slowDownMsg is sent when a host answered 44 SLOW DOWN, or a request was
+// not sent because the host is still in its back-off window
+type slowDownMsg struct {
+	url   string
+	host  string
+	until time.Time
+}
+
+// slowDownTickMsg updates the countdown of a slow down every second
+type slowDownTickMsg struct {
+	until time.Time
+}
+
+// slowDown is a countdown until a host may be asked for a page again
+type slowDown struct {
+	url   string
+	host  string
+	until time.Time
+
+	// retry reloads url when the countdown ends
+	retry bool
+}
+
+// active reports whether a countdown is running
+func (s slowDown) active() bool {
+	return !s.until.IsZero()
+}
+
+// startSlowDown begins the countdown for a host that asked to slow down.
+// Short waits, up to the configured limit, are followed by a reload
+func (m *Model) startSlowDown(msg slowDownMsg) tea.Cmd {
+	m.finishLoading()
+
+	wait := time.Until(msg.until)
+	limit := m.config.Network.RetrySlowDown.Duration
+	m.slowDown = slowDown{
+		url:   msg.url,
+		host:  msg.host,
+		until: msg.until,
+		retry: limit > 0 && wait <= limit,
+	}
+	m.statusMsg = ""
+	return slowDownTick(msg.until)
+}
+
+// updateSlowDown advances the countdown, reloading the page once it ends
+func (m *Model) updateSlowDown(msg slowDownTickMsg) tea.Cmd {
+	// Ticks of a cancelled or replaced countdown are dropped
+	if !msg.until.Equal(m.slowDown.until) {
+		return nil
+	}
+
+	if time.Now().Before(m.slowDown.until) {
+		return slowDownTick(m.slowDown.until)
+	}
+
+	s := m.slowDown
+	m.slowDown = slowDown{}
+	if s.retry && s.url == m.currentURL {
+		return m.loadURL(s.url)
+	}
+	m.statusMsg = fmt.Sprintf("%s is accepting requests again (ctrl+r to reload)", s.host)
+	return nil
+}
+
+// cancelSlowDown stops the countdown without reloading
+func (m *Model) cancelSlowDown() {
+	m.slowDown = slowDown{}
+	m.statusMsg = "Cancelled"
+}
+
+// slowDownStatus describes the countdown for the status bar
+func (m Model) slowDownStatus() string {
+	remaining := time.Until(m.slowDown.until).Round(time.Second)
+	if m.slowDown.retry {
+		return fmt.Sprintf("%s asked to slow down: retrying in %s (esc to cancel)", m.slowDown.host, remaining)
+	}
+	return fmt.Sprintf("%s asked to slow down: wait %s before reloading", m.slowDown.host, remaining)
+}
+
+// slowDownTick returns a command that ticks the countdown ending at until
+func slowDownTick(until time.Time) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return slowDownTickMsg{until: until}
+	})
+}
@@ -0,0 +1,275 @@
+package ui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/watson-ij/gemini/internal/parser"
+	"github.com/watson-ij/gemini/internal/protocol"
+)
+
+const (
+	// streamBufferSize is the read buffer for streamed bodies; whatever has
+	// arrived in it is rendered as one chunk
+	streamBufferSize = 64 * 1024
+
+	// maxStreamChunkLines caps how many lines are delivered per chunk so the
+	// viewport keeps updating on fast, endless streams
+	maxStreamChunkLines = 1000
+
+	// streamRenderInterval is how often the viewport is refreshed while a
+	// stream is arriving faster than it can be redrawn
+	streamRenderInterval = 100 * time.Millisecond
+
+	// maxRawContent caps how much of a streamed body is kept as the page
+	// source; longer pages are still shown in full but are not cached
+	maxRawContent = 4 << 20
+)
+
+// pageStream is a success response body being parsed as it arrives
+type pageStream struct {
+	ctx    context.Context
+	resp   *protocol.Response
+	reader *bufio.Reader
+	parser parser.LineParser
+
+	// started is when the request was sent; header is how long the
+	// response header took to arrive
+	started time.Time
+	header  time.Duration
+
+	// notes describe how the page was fetched and decoded, for the status bar
+	notes []string
+
+	// raw is the page source received so far, up to maxRawContent bytes;
+	// truncated is set once more than that has arrived
+	raw       strings.Builder
+	truncated bool
+
+	// rendered is the viewport text for the first renderedLines lines of
+	// the document, which hold renderedLinks links. Each chunk renders only
+	// its own lines onto the end of it
+	rendered      strings.Builder
+	renderedLines int
+	renderedLinks int
+
+	// lastRender is when the viewport was last refreshed; renderPending is
+	// set while a refresh is scheduled
+	lastRender    time.Time
+	renderPending bool
+}
+
+// streamStartMsg is sent when a success response header has been received
+// and its body is ready to be streamed
+type streamStartMsg struct {
+	stream *pageStream
+}
+
+// streamChunkMsg carries the lines parsed from the latest chunk of a stream
+type streamChunkMsg struct {
+	stream *pageStream
+	lines  []*parser.Line
+	raw    string
+	done   bool
+	err    error
+}
+
+// streamRenderMsg refreshes the viewport with the lines of a stream that
+// arrived too soon after the last refresh to be shown
+type streamRenderMsg struct {
+	stream *pageStream
+}
+
+// newPageStream wraps a success response for incremental parsing
+func newPageStream(ctx context.Context, resp *protocol.Response, lp parser.LineParser) *pageStream {
+	s := &pageStream{
+		ctx:    ctx,
+		resp:   resp,
+		parser: lp,
+	}
+
+	// Text in other charsets is decoded to UTF-8 before it is parsed;
+	// text in charsets we can't decode is shown as it is
+	body, err := resp.TextBody()
+	if err != nil {
+		body = resp.Body
+		s.notes = append(s.notes, err.Error()+", shown as UTF-8")
+	}
+	s.reader = bufio.NewReaderSize(body, streamBufferSize)
+	return s
+}
+
+// next returns a command that reads the next chunk of the stream.
+// A chunk ends when no more complete data is buffered, so lines are
+// delivered as soon as the server sends them
+func (s *pageStream) next() tea.Cmd {
+	return func() tea.Msg {
+		var (
+			lines []*parser.Line
+			raw   strings.Builder
+		)
+
+		for {
+			text, err := s.reader.ReadString('\n')
+			if text != "" {
+				raw.WriteString(text)
+				lines = append(lines, parser.ExpandLine(s.parser, strings.TrimRight(text, "\r\n"))...)
+			}
+
+			if err == io.EOF {
+				// Parsers that need the whole body produce their lines now
+				lines = append(lines, parser.Flush(s.parser)...)
+				return streamChunkMsg{stream: s, lines: lines, raw: raw.String(), done: true}
+			}
+			if err != nil {
+				return streamChunkMsg{stream: s, lines: lines, raw: raw.String(), err: err}
+			}
+
+			if s.reader.Buffered() == 0 || len(lines) >= maxStreamChunkLines {
+				return streamChunkMsg{stream: s, lines: lines, raw: raw.String()}
+			}
+		}
+	}
+}
+
+// close releases the connection behind the stream
+func (s *pageStream) close() {
+	s.resp.Close()
+}
+
+// keepRaw adds received text to the page source, up to maxRawContent
+func (s *pageStream) keepRaw(text string) {
+	if s.truncated {
+		return
+	}
+	if s.raw.Len()+len(text) > maxRawContent {
+		s.truncated = true
+		return
+	}
+	s.raw.WriteString(text)
+}
+
+// dropStream abandons the stream currently being displayed, if any
+func (m *Model) dropStream() {
+	if m.stream != nil {
+		m.stream.close()
+		m.stream = nil
+	}
+}
+
+// startStream begins displaying a streamed document
+func (m *Model) startStream(stream *pageStream) tea.Cmd {
+	// The request was stopped or replaced while its header was arriving
+	if stream.ctx.Err() != nil {
+		stream.close()
+		return nil
+	}
+
+	m.dropStream()
+	m.stream = stream
+	if note := m.followRedirects(stream.resp); note != "" {
+		stream.notes = append(stream.notes, note)
+	}
+	m.info = pageInfo{
+		url:     m.currentURL,
+		resp:    stream.resp,
+		started: stream.started,
+		header:  stream.header,
+	}
+	m.document = parser.NewDocument()
+	m.document.Lang = stream.resp.Lang()
+	m.rawContent = ""
+	m.rawComplete = false
+	m.selectedLink = -1
+	m.viewport.GotoTop()
+	m.renderDocument()
+
+	return stream.next()
+}
+
+// appendStreamChunk adds newly received lines to the current document
+func (m *Model) appendStreamChunk(msg streamChunkMsg) tea.Cmd {
+	// Chunks from a stopped or replaced stream are dropped
+	if msg.stream != m.stream {
+		msg.stream.close()
+		return nil
+	}
+
+	for _, line := range msg.lines {
+		m.document.AddLine(line)
+	}
+	msg.stream.keepRaw(msg.raw)
+	m.rawContent = msg.stream.raw.String()
+
+	if !msg.done && msg.err == nil {
+		return tea.Batch(m.renderStream(msg.stream), msg.stream.next())
+	}
+	m.refreshStream(msg.stream)
+
+	m.stream = nil
+	msg.stream.close()
+	m.finishLoading()
+	m.info.total = time.Since(msg.stream.started)
+
+	if msg.err != nil {
+		m.err = msg.err
+		m.statusMsg = fmt.Sprintf("Error: %v", msg.err)
+		return nil
+	}
+
+	if msg.stream.truncated {
+		msg.stream.notes = append(msg.stream.notes, fmt.Sprintf("over %s, not cached", formatBytes(maxRawContent)))
+	} else {
+		m.rawComplete = true
+		m.cachePage(m.currentURL, msg.stream.resp, m.rawContent)
+	}
+
+	m.statusMsg = fmt.Sprintf("Loaded %d lines, %d links", m.document.LineCount(), m.document.LinkCount())
+	if len(msg.stream.notes) > 0 {
+		m.statusMsg += " (" + strings.Join(msg.stream.notes, "; ") + ")"
+	}
+	return nil
+}
+
+// renderStream shows the lines of a stream's latest chunk. The viewport is
+// refreshed straight away unless it was refreshed within
+// streamRenderInterval, in which case a refresh is scheduled, so that a
+// fast stream is not redrawn for every chunk
+func (m *Model) renderStream(s *pageStream) tea.Cmd {
+	wait := streamRenderInterval - time.Since(s.lastRender)
+	if wait <= 0 {
+		m.refreshStream(s)
+		return nil
+	}
+	if s.renderPending {
+		return nil
+	}
+
+	s.renderPending = true
+	return tea.Tick(wait, func(time.Time) tea.Msg {
+		return streamRenderMsg{stream: s}
+	})
+}
+
+// refreshStream renders the lines a stream has added since the last
+// refresh and shows them, following the end of the document if the user
+// has scrolled there
+func (m *Model) refreshStream(s *pageStream) {
+	text, links := m.renderer().RenderFrom(m.document, s.renderedLines, s.renderedLinks)
+	s.rendered.WriteString(text)
+	s.renderedLines, s.renderedLinks = len(m.document.Lines), links
+
+	follow := m.viewport.YOffset > 0 && m.viewport.AtBottom()
+	m.viewport.SetContent(s.rendered.String())
+	if follow {
+		m.viewport.GotoBottom()
+	}
+
+	s.lastRender = time.Now()
+	s.renderPending = false
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/watson-ij/gemini/internal/ui"
@@ -15,6 +18,15 @@ func main() {
 	// Check for URL argument
 	if len(os.Args) > 1 {
 		startURL = os.Args[1]
+
+		// A local path opens as a file:// URL, for previewing capsules
+		if !strings.Contains(startURL, "://") {
+			if abs, err := filepath.Abs(startURL); err == nil {
+				if _, err := os.Stat(abs); err == nil {
+					startURL = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+				}
+			}
+		}
 	}
 
 	// Create the model